@@ -0,0 +1,211 @@
+package jkl
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const apiCacheSubDir = "api"
+
+// DefaultAPICacheTTL is how long a cached API response is used before the
+// API is asked whether the response has changed.
+const DefaultAPICacheTTL = 5 * time.Minute
+
+// APICache stores responses from the Github and Hashicorp APIs, such as
+// release listings. Cached responses are used without contacting the API
+// until they are older than the TTL, after which a conditional request
+// revalidates them using their ETag or Last-Modified time. Cached responses
+// are also used in offline mode.
+type APICache struct {
+	dir string
+	ttl time.Duration
+}
+
+// NewAPICache returns an APICache which stores responses in the specified
+// directory, and uses them for the specified TTL. A TTL of zero
+// revalidates every cached response. The directory is created when the first
+// response is stored.
+func NewAPICache(dir string, ttl time.Duration) (*APICache, error) {
+	if dir == "" {
+		return nil, errors.New("the cache directory cannot be empty")
+	}
+	if ttl < 0 {
+		return nil, errors.New("the API cache TTL cannot be negative")
+	}
+	return &APICache{dir: dir, ttl: ttl}, nil
+}
+
+// cachedAPIResponse is an API response stored in an APICache.
+type cachedAPIResponse struct {
+	URL          string    `json:"url"`
+	StatusCode   int       `json:"status_code"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Body         []byte    `json:"body"`
+	Fetched      time.Time `json:"fetched"` // when the response was last fetched or revalidated
+}
+
+// entryPath returns the file where the response for the specified URL is
+// stored.
+func (c APICache) entryPath(URL string) string {
+	URLHash := sha256.Sum256([]byte(URL))
+	return filepath.Join(c.dir, apiCacheSubDir, hex.EncodeToString(URLHash[:])+".json")
+}
+
+// get returns the stored response for the specified URL.
+func (c APICache) get(URL string) (cachedResp cachedAPIResponse, found bool, err error) {
+	b, err := os.ReadFile(c.entryPath(URL))
+	if errors.Is(err, fs.ErrNotExist) {
+		return cachedAPIResponse{}, false, nil
+	}
+	if err != nil {
+		return cachedAPIResponse{}, false, err
+	}
+	err = json.Unmarshal(b, &cachedResp)
+	if err != nil {
+		return cachedAPIResponse{}, false, fmt.Errorf("invalid cached API response for %s: %v", URL, err)
+	}
+	if cachedResp.URL != URL {
+		return cachedAPIResponse{}, false, nil
+	}
+	return cachedResp, true, nil
+}
+
+// put atomically stores the response for its URL.
+func (c APICache) put(cachedResp cachedAPIResponse) error {
+	b, err := json.Marshal(cachedResp)
+	if err != nil {
+		return err
+	}
+	entryPath := c.entryPath(cachedResp.URL)
+	err = os.MkdirAll(filepath.Dir(entryPath), 0700)
+	if err != nil {
+		return err
+	}
+	tempFile, err := os.CreateTemp(filepath.Dir(entryPath), "."+filepath.Base(entryPath)+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+	_, err = tempFile.Write(b)
+	closeErr := tempFile.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	return os.Rename(tempFile.Name(), entryPath)
+}
+
+// Clear removes all cached API responses.
+func (c APICache) Clear() error {
+	debugLog.Printf("clearing the API cache %s", c.dir)
+	return os.RemoveAll(filepath.Join(c.dir, apiCacheSubDir))
+}
+
+// toHTTPResponse returns an http.Response with the stored status and body.
+func (r cachedAPIResponse) toHTTPResponse(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode)),
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{},
+		Body:          io.NopCloser(bytes.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
+
+// isCacheableAPIStatus returns true for API response status codes that are
+// stored in an APICache. A "not found" response is stored, as it answers
+// questions like whether a version exists.
+func isCacheableAPIStatus(statusCode int) bool {
+	return statusCode == http.StatusOK || statusCode == http.StatusNotFound
+}
+
+// doAPIRequest sends the API request, storing the response in the APICache
+// if one is specified. A cached response is returned without sending the
+// request if it is newer than the TTL of the APICache, otherwise the
+// request is sent conditionally and the cached response is returned if the
+// API responds that it was not modified.
+// In offline mode, the request is not sent and the previously stored
+// response is returned, or an error if the response was never stored.
+func doAPIRequest(do requestDoer, req *http.Request, cache *APICache, offline bool) (*http.Response, error) {
+	URL := req.URL.String()
+	if offline && cache == nil {
+		return nil, fmt.Errorf("cannot request %s in offline mode", URL)
+	}
+	if cache == nil {
+		return do(req)
+	}
+	cachedResp, found, err := cache.get(URL)
+	if err != nil {
+		debugLog.Printf("ignoring the cached response for %s: %v", URL, err)
+		found = false
+	}
+	if offline {
+		if !found {
+			return nil, fmt.Errorf("%s has not been fetched previously, and cannot be requested in offline mode", URL)
+		}
+		debugLog.Printf("using the response for %s cached at %v, as jkl is offline", URL, cachedResp.Fetched)
+		return cachedResp.toHTTPResponse(req), nil
+	}
+	if found && time.Since(cachedResp.Fetched) < cache.ttl {
+		debugLog.Printf("using the response for %s cached at %v, which is within the TTL of %v", URL, cachedResp.Fetched, cache.ttl)
+		return cachedResp.toHTTPResponse(req), nil
+	}
+	if found && cachedResp.ETag != "" {
+		req.Header.Set("If-None-Match", cachedResp.ETag)
+	}
+	if found && cachedResp.LastModified != "" {
+		req.Header.Set("If-Modified-Since", cachedResp.LastModified)
+	}
+	resp, err := do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified && found {
+		resp.Body.Close()
+		debugLog.Printf("the response for %s has not been modified since it was cached", URL)
+		cachedResp.Fetched = time.Now()
+		err = cache.put(cachedResp)
+		if err != nil {
+			debugLog.Printf("cannot update the cached response for %s: %v", URL, err)
+		}
+		return cachedResp.toHTTPResponse(req), nil
+	}
+	if !isCacheableAPIStatus(resp.StatusCode) {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("while reading the response for %s: %v", URL, err)
+	}
+	err = cache.put(cachedAPIResponse{
+		URL:          URL,
+		StatusCode:   resp.StatusCode,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Body:         body,
+		Fetched:      time.Now(),
+	})
+	if err != nil {
+		debugLog.Printf("cannot cache the response for %s: %v", URL, err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
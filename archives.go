@@ -6,14 +6,19 @@ import (
 	"bytes"
 	"compress/bzip2"
 	"compress/gzip"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
 	"github.com/h2non/filetype"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
 )
 
 // fileTypeReader extends io.Reader by providing the file type, determined by
@@ -45,6 +50,11 @@ func NewFileTypeReader(f io.Reader) (ftr *fileTypeReader, fileType string, err e
 		return nil, "", err
 	}
 	ftr.fileType = contentType.Extension
+	// The filetype package matches Debian packages as either "deb" or the more
+	// generic "ar", depending on the order of its matchers.
+	if ftr.fileType == "ar" && bytes.HasPrefix(buffer[:n], []byte("!<arch>\ndebian-binary")) {
+		ftr.fileType = "deb"
+	}
 	return ftr, ftr.fileType, nil
 }
 
@@ -53,20 +63,77 @@ func (f *fileTypeReader) Type() string {
 	return f.fileType
 }
 
-// ExtractFile uncompresses and unarchives a file of type gzip, bzip2, tar,
-// and zip, into the same path as the source file. If the file is not one of these types, wasExtracted returns
+const (
+	// DefaultMaxExtractedBytes is the default limit of the total uncompressed
+	// size of files extracted from an archive.
+	DefaultMaxExtractedBytes int64 = 2 << 30 // 2GiB
+	// DefaultMaxExtractedFiles is the default limit of the number of files
+	// extracted from an archive.
+	DefaultMaxExtractedFiles int = 10000
+)
+
+// extractor holds the limits and accounting used while extracting a file,
+// which protect against archives that would escape the destination directory
+// or exhaust disk space.
+type extractor struct {
+	ctx            context.Context // stops extraction once done
+	destDirName    string
+	maxBytes       int64
+	maxFiles       int
+	extractedBytes int64
+	extractedFiles int
+	includeMember  func(memberPath string) bool // optionally filters which archive members are extracted
+}
+
+// ExtractOption uses a function to set fields on an extractor, which
+// is used by ExtractFile().
+type ExtractOption func(*extractor)
+
+// WithMaxExtractedBytes limits the total uncompressed size of files that
+// ExtractFile() will write.
+func WithMaxExtractedBytes(n int64) ExtractOption {
+	return func(e *extractor) {
+		e.maxBytes = n
+	}
+}
+
+// WithMaxExtractedFiles limits the number of files that ExtractFile() will
+// write.
+func WithMaxExtractedFiles(n int) ExtractOption {
+	return func(e *extractor) {
+		e.maxFiles = n
+	}
+}
+
+// ExtractFile uncompresses and unarchives a file of type gzip, bzip2, xz,
+// zstd, tar, and zip, into the same path as the source file.
+// Executables are extracted from Debian and RPM packages. If the file is not one of these types, wasExtracted returns
 // false.
-func ExtractFile(filePath string) (wasExtracted bool, err error) {
+// Archive members which would be written outside of the destination
+// directory return an error, as do archives exceeding the limits set by
+// WithMaxExtractedBytes() and WithMaxExtractedFiles().
+// Extraction stops with an error once the context is done.
+func ExtractFile(ctx context.Context, filePath string, options ...ExtractOption) (wasExtracted bool, err error) {
 	absFilePath, err := filepath.Abs(filePath)
 	if err != nil {
 		return false, err
 	}
 	destDirName := filepath.Dir(absFilePath)
+	e := &extractor{
+		ctx:         ctx,
+		destDirName: destDirName,
+		maxBytes:    DefaultMaxExtractedBytes,
+		maxFiles:    DefaultMaxExtractedFiles,
+	}
+	for _, option := range options {
+		option(e)
+	}
 	debugLog.Printf("extracting file %q into directory %q", absFilePath, destDirName)
 	f, err := os.Open(absFilePath)
 	if err != nil {
 		return false, err
 	}
+	defer f.Close()
 	fileStat, err := f.Stat()
 	if err != nil {
 		return false, err
@@ -80,17 +147,37 @@ func ExtractFile(filePath string) (wasExtracted bool, err error) {
 	fileName := filepath.Base(filePath)
 	switch fileType {
 	case "gz":
-		err := gunzipFile(ftr, destDirName)
+		err := e.gunzipFile(ftr, absFilePath)
 		if err != nil {
 			return false, err
 		}
 	case "bz2":
-		err := bunzip2File(ftr, absFilePath)
+		err := e.bunzip2File(ftr, absFilePath)
+		if err != nil {
+			return false, err
+		}
+	case "xz":
+		err := e.unxzFile(ftr, absFilePath)
+		if err != nil {
+			return false, err
+		}
+	case "zst":
+		err := e.unzstdFile(ftr, absFilePath)
 		if err != nil {
 			return false, err
 		}
 	case "tar":
-		err = extractTarFile(ftr, destDirName)
+		err = e.extractTarFile(ftr)
+		if err != nil {
+			return false, err
+		}
+	case "deb":
+		err = e.extractDebFile(ftr)
+		if err != nil {
+			return false, err
+		}
+	case "rpm":
+		err = e.extractRPMFile(ftr)
 		if err != nil {
 			return false, err
 		}
@@ -99,7 +186,7 @@ func ExtractFile(filePath string) (wasExtracted bool, err error) {
 		// io.Reader.
 		// The unzip pkg explicitly positions the ReaderAt, therefore is not
 		// impacted by the fileTypeReader having read the first 512 bytes above.
-		err = extractZipFile(f, destDirName, fileSize)
+		err = e.extractZipFile(f, fileSize)
 		if err != nil {
 			return false, err
 		}
@@ -110,9 +197,67 @@ func ExtractFile(filePath string) (wasExtracted bool, err error) {
 	return true, nil
 }
 
-// saveAs writes the content of an io.Reader to the specified file. If the
+// archiveMemberPath validates the name of an archive member, returning it
+// cleaned and using forward slashes. Absolute names, and names which
+// reference a parent of the archive root, return an error.
+func archiveMemberPath(name string) (string, error) {
+	slashedName := strings.ReplaceAll(name, `\`, "/")
+	if path.IsAbs(slashedName) || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("refusing to extract %q which has an absolute path", name)
+	}
+	cleanName := path.Clean(slashedName)
+	if cleanName == ".." || strings.HasPrefix(cleanName, "../") {
+		return "", fmt.Errorf("refusing to extract %q which is outside of the archive directory", name)
+	}
+	return cleanName, nil
+}
+
+// flatDestPath returns the path in the destination directory where the
+// specified archive member is extracted.
+// filepath.Base() is used to keep the directory structure flat.
+func (e *extractor) flatDestPath(memberPath string) string {
+	return filepath.Join(e.destDirName, path.Base(memberPath))
+}
+
+// includes returns true if the specified archive member should be
+// extracted.
+func (e *extractor) includes(memberPath string) bool {
+	return e.includeMember == nil || e.includeMember(memberPath)
+}
+
+// countFile increments the number of extracted files, returning an error if
+// that exceeds the limit.
+func (e *extractor) countFile(name string) error {
+	e.extractedFiles++
+	if e.extractedFiles > e.maxFiles {
+		return fmt.Errorf("aborting extraction at %q, the archive contains more than the maximum of %d files", name, e.maxFiles)
+	}
+	return nil
+}
+
+// removeExistingLink removes filePath if it is a symbolic link, so a
+// subsequent write replaces the link instead of following it.
+func removeExistingLink(filePath string) error {
+	stat, err := os.Lstat(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if stat.Mode()&fs.ModeSymlink != 0 {
+		debugLog.Printf("removing existing symlink %q", filePath)
+		return os.Remove(filePath)
+	}
+	return nil
+}
+
+// saveAs writes the content of an io.Reader to the specified file with the
+// specified permissions. If the
 // base directory does not exist, it will be created.
-func saveAs(r io.Reader, filePath string) error {
+// Writing beyond the extractor limit of total bytes returns an error, and the
+// partially written file is removed.
+func (e *extractor) saveAs(r io.Reader, filePath string, mode fs.FileMode) error {
 	baseDir := filepath.Dir(filePath)
 	_, err := os.Stat(baseDir)
 	if os.IsNotExist(err) {
@@ -125,80 +270,189 @@ func saveAs(r io.Reader, filePath string) error {
 	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
-	f, err := os.Create(filePath)
+	err = removeExistingLink(filePath)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
 		return fmt.Errorf("Cannot open %s: %v", filePath, err)
 	}
 	defer f.Close()
-	debugLog.Printf("saving to file %s\n", filePath)
-	_, err = io.Copy(f, r)
+	// The owner can always read and write what is extracted, and any other
+	// permissions come from the archive.
+	mode = mode.Perm() | 0600
+	err = f.Chmod(mode)
 	if err != nil {
+		return fmt.Errorf("cannot set mode on %s: %v", filePath, err)
+	}
+	debugLog.Printf("saving to file %s with mode %v\n", filePath, mode)
+	remainingBytes := e.maxBytes - e.extractedBytes
+	n, err := io.Copy(f, io.LimitReader(contextReader{ctx: e.ctx, r: r}, remainingBytes+1))
+	e.extractedBytes += n
+	if err == nil && n > remainingBytes {
+		err = fmt.Errorf("the total extracted size exceeds the maximum of %d bytes", e.maxBytes)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(filePath)
 		return fmt.Errorf("Cannot write to %s: %v", filePath, err)
 	}
 	return nil
 }
 
-// gunzipFile uses gunzip to decompress the specified io.Reader into
-// destDirName. If the result is a tar file, it will be extracted, otherwise the io.Reader is written to
-// a file using saveAs().
-func gunzipFile(r io.Reader, destDirName string) error {
-	gzipReader, err := gzip.NewReader(r)
-	if err != nil {
-		return err
-	}
-	defer gzipReader.Close()
-	fileName := gzipReader.Header.Name
-	debugLog.Printf("decompressing gzip, optional file name is %q\n", fileName)
-	ftr, fileType, err := NewFileTypeReader(gzipReader)
+// saveDecompressed extracts the decompressed io.Reader if it is a tar file,
+// otherwise the io.Reader is written to filePath using saveAs().
+func (e *extractor) saveDecompressed(r io.Reader, compression, filePath string) error {
+	ftr, fileType, err := NewFileTypeReader(r)
 	if err != nil {
 		return err
 	}
 	if fileType == "tar" {
-		err := extractTarFile(ftr, destDirName)
+		err := e.extractTarFile(ftr)
 		if err != nil {
-			return fmt.Errorf("while extracting ungzipped tar: %v", err)
+			return fmt.Errorf("while extracting %s compressed tar: %v", compression, err)
 		}
 		return nil
 	}
 	debugLog.Println("nothing to unarchive, saving direct file.")
-	err = saveAs(ftr, filepath.Join(destDirName, fileName))
+	err = e.countFile(filePath)
+	if err != nil {
+		return err
+	}
+	err = e.saveAs(ftr, filePath, 0644)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// trimCompressionExtension returns the base name of filePath minus the
+// specified extensions, which are matched case-insensitively.
+func trimCompressionExtension(filePath string, extensions ...string) string {
+	fileName := filepath.Base(filePath)
+	for _, ext := range extensions {
+		if strings.HasSuffix(strings.ToLower(fileName), ext) && len(fileName) > len(ext) {
+			return fileName[:len(fileName)-len(ext)]
+		}
+	}
+	return fileName
+}
+
+// gunzipFile uses gunzip to decompress the specified io.Reader into
+// the destination directory. If the result is a tar file, it will be extracted, otherwise the io.Reader is written to
+// the file name from the gzip header, or the original name minus the .gz
+// extension, using saveAs().
+func (e *extractor) gunzipFile(r io.Reader, filePath string) error {
+	gzipReader, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gzipReader.Close()
+	fileName := gzipReader.Header.Name
+	debugLog.Printf("decompressing gzip, optional file name is %q\n", fileName)
+	if fileName == "" {
+		fileName = trimCompressionExtension(filePath, ".gz")
+	}
+	return e.saveDecompressed(gzipReader, "gzip", filepath.Join(e.destDirName, filepath.Base(fileName)))
+}
+
 // bunzip2File uses bzip2 to decompress the specified io.Reader into
 // the same directory. If the result is a tar file, it will be extracted, otherwise the io.Reader is written to
 // the original name minus the .bz2 extension, using saveAs().
-func bunzip2File(r io.Reader, filePath string) error {
+func (e *extractor) bunzip2File(r io.Reader, filePath string) error {
 	debugLog.Println("decompressing bzip2")
 	bzip2Reader := bzip2.NewReader(r)
-	baseFileName := strings.TrimSuffix(filePath, ".bz2")
-	baseFileName = strings.TrimSuffix(baseFileName, ".BZ2")
-	ftr, fileType, err := NewFileTypeReader(bzip2Reader)
+	fileName := trimCompressionExtension(filePath, ".bz2")
+	return e.saveDecompressed(bzip2Reader, "bzip2", filepath.Join(e.destDirName, fileName))
+}
+
+// unxzFile uses xz to decompress the specified io.Reader into
+// the same directory. If the result is a tar file, it will be extracted, otherwise the io.Reader is written to
+// the original name minus the .xz extension, using saveAs().
+func (e *extractor) unxzFile(r io.Reader, filePath string) error {
+	debugLog.Println("decompressing xz")
+	xzReader, err := xz.NewReader(r)
 	if err != nil {
 		return err
 	}
-	if fileType == "tar" {
-		err := extractTarFile(ftr, filepath.Dir(filePath))
-		if err != nil {
-			return fmt.Errorf("while extracting bunzip2ed tar: %v", err)
-		}
+	fileName := trimCompressionExtension(filePath, ".xz")
+	return e.saveDecompressed(xzReader, "xz", filepath.Join(e.destDirName, fileName))
+}
+
+// unzstdFile uses zstd to decompress the specified io.Reader into
+// the same directory. If the result is a tar file, it will be extracted, otherwise the io.Reader is written to
+// the original name minus the .zst extension, using saveAs().
+func (e *extractor) unzstdFile(r io.Reader, filePath string) error {
+	debugLog.Println("decompressing zstd")
+	zstdReader, err := zstd.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer zstdReader.Close()
+	fileName := trimCompressionExtension(filePath, ".zst", ".zstd")
+	return e.saveDecompressed(zstdReader, "zstd", filepath.Join(e.destDirName, fileName))
+}
+
+// extractSymlink creates a symbolic link for an archive member, whose target
+// must be relative and remain within the archive.
+// Because the directory structure is flattened, the link points to the base
+// name of its target.
+func (e *extractor) extractSymlink(memberPath, linkTarget string) error {
+	if path.IsAbs(linkTarget) || filepath.IsAbs(linkTarget) {
+		return fmt.Errorf("refusing to extract symlink %q with absolute target %q", memberPath, linkTarget)
+	}
+	resolvedTarget, err := archiveMemberPath(path.Join(path.Dir(memberPath), linkTarget))
+	if err != nil {
+		return fmt.Errorf("refusing to extract symlink %q whose target %q is outside of the archive directory", memberPath, linkTarget)
+	}
+	flatTarget := path.Base(resolvedTarget)
+	if flatTarget == path.Base(memberPath) {
+		debugLog.Printf("skipping symlink %q which would point to itself once extracted without sub-directories", memberPath)
 		return nil
 	}
-	debugLog.Println("nothing to unarchive, saving direct file.")
-	err = saveAs(ftr, baseFileName)
+	err = e.countFile(memberPath)
 	if err != nil {
 		return err
 	}
-	return nil
+	linkPath := e.flatDestPath(memberPath)
+	err = os.Remove(linkPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	debugLog.Printf("creating symlink %q -> %q", linkPath, flatTarget)
+	return os.Symlink(flatTarget, linkPath)
+}
+
+// extractHardlink links an archive member to a previously extracted
+// member.
+func (e *extractor) extractHardlink(memberPath, linkTarget string) error {
+	targetPath, err := archiveMemberPath(linkTarget)
+	if err != nil {
+		return fmt.Errorf("refusing to extract hard link %q: %v", memberPath, err)
+	}
+	err = e.countFile(memberPath)
+	if err != nil {
+		return err
+	}
+	linkPath := e.flatDestPath(memberPath)
+	flatTargetPath := e.flatDestPath(targetPath)
+	if linkPath == flatTargetPath {
+		debugLog.Printf("skipping hard link %q which would link to itself once extracted without sub-directories", memberPath)
+		return nil
+	}
+	err = os.Remove(linkPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	debugLog.Printf("creating hard link %q to %q", linkPath, flatTargetPath)
+	return os.Link(flatTargetPath, linkPath)
 }
 
 // extractTarFile uses tar to extract the specified io.Reader into
-// destDIrName.
+// the destination directory.
 // Files are extracted in a flat hierarchy, without their sub-directories.
-func extractTarFile(r io.Reader, destDirName string) error {
+func (e *extractor) extractTarFile(r io.Reader) error {
 	debugLog.Println("extracting tar")
 	tarReader := tar.NewReader(r)
 	for {
@@ -210,6 +464,17 @@ func extractTarFile(r io.Reader, destDirName string) error {
 		if err != nil {
 			return err
 		}
+		if header.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		memberPath, err := archiveMemberPath(header.Name)
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeDir && !e.includes(memberPath) {
+			debugLog.Printf("skipping %q which is not included for extraction", header.Name)
+			continue
+		}
 		switch header.Typeflag {
 		case tar.TypeDir:
 			debugLog.Printf("skipping directory %q", header.Name)
@@ -221,11 +486,30 @@ func extractTarFile(r io.Reader, destDirName string) error {
 			}
 			*/
 		case tar.TypeReg:
-			// filepath.Base() is used to keep the directory structure flat.
-			err = saveAs(tarReader, filepath.Join(destDirName, filepath.Base(header.Name)))
+			if header.Size > e.maxBytes-e.extractedBytes {
+				return fmt.Errorf("aborting extraction at %q, the total extracted size would exceed the maximum of %d bytes", header.Name, e.maxBytes)
+			}
+			err = e.countFile(header.Name)
 			if err != nil {
 				return err
 			}
+			err = e.saveAs(tarReader, e.flatDestPath(memberPath), header.FileInfo().Mode())
+			if err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			err = e.extractSymlink(memberPath, header.Linkname)
+			if err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			err = e.extractHardlink(memberPath, header.Linkname)
+			if err != nil {
+				return err
+			}
+		case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+			debugLog.Printf("skipping special file %q of type %q", header.Name, header.Typeflag)
+			continue
 		default:
 			return fmt.Errorf("aborting extraction, unknown file type %q for file %q in tar file", header.Typeflag, header.Name)
 		}
@@ -233,16 +517,21 @@ func extractTarFile(r io.Reader, destDirName string) error {
 	return nil
 }
 
-// extractZipFile uses zip to extract the specified os.File into destDirName.
+// extractZipFile uses zip to extract the specified os.File into the
+// destination directory.
 // Files are extracted in a flat hierarchy, without their sub-directories.
-func extractZipFile(f *os.File, destDirName string, size int64) error {
+func (e *extractor) extractZipFile(f *os.File, size int64) error {
 	debugLog.Println("extracting zip")
 	zipReader, err := zip.NewReader(f, size)
 	if err != nil {
 		return err
 	}
 	for _, zrf := range zipReader.File {
-		if strings.HasSuffix(zrf.Name, "/") {
+		memberPath, err := archiveMemberPath(zrf.Name)
+		if err != nil {
+			return err
+		}
+		if strings.HasSuffix(zrf.Name, "/") || zrf.Mode().IsDir() {
 			debugLog.Printf("Skipping directory %q", zrf.Name)
 			continue
 		}
@@ -250,12 +539,33 @@ func extractZipFile(f *os.File, destDirName string, size int64) error {
 		if err != nil {
 			return fmt.Errorf("cannot open %s in zip file: %v", zrf.Name, err)
 		}
-		// filepath.Base() is used to keep the directory structure flat.
-		saveFileName := filepath.Join(destDirName, filepath.Base(zrf.Name))
-		err = saveAs(zf, saveFileName)
+		if zrf.Mode()&fs.ModeSymlink != 0 {
+			// The content of a zipped symlink is its target.
+			linkTarget, err := io.ReadAll(io.LimitReader(zf, 4096))
+			zf.Close()
+			if err != nil {
+				return fmt.Errorf("cannot read symlink %s in zip file: %v", zrf.Name, err)
+			}
+			err = e.extractSymlink(memberPath, string(linkTarget))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if zrf.UncompressedSize64 > uint64(e.maxBytes-e.extractedBytes) {
+			zf.Close()
+			return fmt.Errorf("aborting extraction at %q, the total extracted size would exceed the maximum of %d bytes", zrf.Name, e.maxBytes)
+		}
+		err = e.countFile(zrf.Name)
 		if err != nil {
 			zf.Close()
-			return fmt.Errorf("Cannot write to %s: %v", saveFileName, err)
+			return err
+		}
+		saveFileName := e.flatDestPath(memberPath)
+		err = e.saveAs(zf, saveFileName, zrf.Mode())
+		if err != nil {
+			zf.Close()
+			return err
 		}
 		zf.Close()
 	}
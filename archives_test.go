@@ -1,6 +1,7 @@
 package jkl_test
 
 import (
+	"context"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -17,9 +18,12 @@ func TestExtractFile(t *testing.T) {
 	testCases := []struct {
 		description     string
 		archiveFilePath string
+		extractOptions  []jkl.ExtractOption
 		extractedFiles  []string
+		executableFiles []string // a subset of extractedFiles
 		wasExtracted    bool
 		expectError     bool
+		cancelled       bool // the context is cancelled before extracting
 	}{
 		{
 			description:     "Single file gzip compressed",
@@ -45,6 +49,30 @@ func TestExtractFile(t *testing.T) {
 			extractedFiles:  []string{"file", "file2"},
 			wasExtracted:    true,
 		},
+		{
+			description:     "Single file xz compressed",
+			archiveFilePath: "file.xz",
+			extractedFiles:  []string{"file"},
+			wasExtracted:    true,
+		},
+		{
+			description:     "Single file zstd compressed",
+			archiveFilePath: "file.zst",
+			extractedFiles:  []string{"file"},
+			wasExtracted:    true,
+		},
+		{
+			description:     "tar xz compressed",
+			archiveFilePath: "file.tar.xz",
+			extractedFiles:  []string{"file", "file2"},
+			wasExtracted:    true,
+		},
+		{
+			description:     "tar zstd compressed",
+			archiveFilePath: "file.tar.zst",
+			extractedFiles:  []string{"file", "file2"},
+			wasExtracted:    true,
+		},
 		{
 			description:     "uncompressed tar",
 			archiveFilePath: "file.tar",
@@ -57,6 +85,20 @@ func TestExtractFile(t *testing.T) {
 			extractedFiles:  []string{"file", "file2"},
 			wasExtracted:    true,
 		},
+		{
+			description:     "Debian package",
+			archiveFilePath: "tool_1.2.3_amd64.deb",
+			extractedFiles:  []string{"tool", "tool-alias"},
+			executableFiles: []string{"tool"},
+			wasExtracted:    true,
+		},
+		{
+			description:     "RPM package",
+			archiveFilePath: "tool-1.2.3-1.x86_64.rpm",
+			extractedFiles:  []string{"tool", "tool-alias"},
+			executableFiles: []string{"tool"},
+			wasExtracted:    true,
+		},
 		{
 			description:     "A plain file not in an archive",
 			archiveFilePath: "plain-file",
@@ -75,6 +117,18 @@ func TestExtractFile(t *testing.T) {
 			extractedFiles:  []string{},
 			expectError:     true,
 		},
+		{
+			description:     "Truncated xz which will return an error",
+			archiveFilePath: "truncated.xz",
+			extractedFiles:  []string{},
+			expectError:     true,
+		},
+		{
+			description:     "Truncated zstd which will return an error",
+			archiveFilePath: "truncated.zst",
+			extractedFiles:  []string{},
+			expectError:     true,
+		},
 		{
 			description:     "Truncated tar which will return an error",
 			archiveFilePath: "truncated.tar",
@@ -87,6 +141,127 @@ func TestExtractFile(t *testing.T) {
 			extractedFiles:  []string{},
 			expectError:     true,
 		},
+		{
+			description:     "Truncated Debian package which will return an error",
+			archiveFilePath: "truncated.deb",
+			extractedFiles:  []string{},
+			expectError:     true,
+		},
+		{
+			description:     "Truncated RPM package which will return an error",
+			archiveFilePath: "truncated.rpm",
+			extractedFiles:  []string{},
+			expectError:     true,
+		},
+		{
+			description:     "tar with an absolute path which will return an error",
+			archiveFilePath: "absolute-path.tar",
+			extractedFiles:  []string{},
+			expectError:     true,
+		},
+		{
+			description:     "tar with a parent directory path which will return an error",
+			archiveFilePath: "parent-dir.tar",
+			extractedFiles:  []string{},
+			expectError:     true,
+		},
+		{
+			description:     "tar with a sub-directory path that escapes to a parent directory, which will return an error",
+			archiveFilePath: "nested-parent-dir.tar",
+			extractedFiles:  []string{},
+			expectError:     true,
+		},
+		{
+			description:     "zip with a parent directory path which will return an error",
+			archiveFilePath: "parent-dir.zip",
+			extractedFiles:  []string{},
+			expectError:     true,
+		},
+		{
+			description:     "tar with a symlink",
+			archiveFilePath: "symlink.tar",
+			extractedFiles:  []string{"tool", "tool-link"},
+			executableFiles: []string{"tool"},
+			wasExtracted:    true,
+		},
+		{
+			description:     "zip with a symlink",
+			archiveFilePath: "symlink.zip",
+			extractedFiles:  []string{"tool", "tool-link"},
+			executableFiles: []string{"tool"},
+			wasExtracted:    true,
+		},
+		{
+			description:     "tar with a symlink to an absolute path which will return an error",
+			archiveFilePath: "symlink-absolute.tar",
+			extractedFiles:  []string{},
+			expectError:     true,
+		},
+		{
+			description:     "tar with a symlink to a parent directory which will return an error",
+			archiveFilePath: "symlink-escape.tar",
+			extractedFiles:  []string{},
+			expectError:     true,
+		},
+		{
+			description:     "zip with a symlink to a parent directory which will return an error",
+			archiveFilePath: "symlink-escape.zip",
+			extractedFiles:  []string{},
+			expectError:     true,
+		},
+		{
+			description:     "tar with a hard link",
+			archiveFilePath: "hardlink.tar",
+			extractedFiles:  []string{"file", "file-link"},
+			wasExtracted:    true,
+		},
+		{
+			description:     "tar with a hard link to a parent directory which will return an error",
+			archiveFilePath: "hardlink-escape.tar",
+			extractedFiles:  []string{},
+			expectError:     true,
+		},
+		{
+			description:     "tar with an executable file",
+			archiveFilePath: "executable.tar",
+			extractedFiles:  []string{"README", "tool"},
+			executableFiles: []string{"tool"},
+			wasExtracted:    true,
+		},
+		{
+			description:     "zip with an executable file",
+			archiveFilePath: "executable.zip",
+			extractedFiles:  []string{"README", "tool"},
+			executableFiles: []string{"tool"},
+			wasExtracted:    true,
+		},
+		{
+			description:     "gzip compressed tar larger than the size limit which will return an error",
+			archiveFilePath: "bomb.tar.gz",
+			extractOptions:  []jkl.ExtractOption{jkl.WithMaxExtractedBytes(1024)},
+			extractedFiles:  []string{},
+			expectError:     true,
+		},
+		{
+			description:     "gzip compressed file larger than the size limit which will return an error",
+			archiveFilePath: "bomb.gz",
+			extractOptions:  []jkl.ExtractOption{jkl.WithMaxExtractedBytes(1024)},
+			extractedFiles:  []string{},
+			expectError:     true,
+		},
+		{
+			description:     "tar with more files than the limit which will return an error",
+			archiveFilePath: "many-files.tar",
+			extractOptions:  []jkl.ExtractOption{jkl.WithMaxExtractedFiles(2)},
+			extractedFiles:  []string{"file1", "file2"}, // This will partially extract.
+			expectError:     true,
+		},
+		{
+			description:     "tar gzip compressed with a cancelled context which will return an error",
+			archiveFilePath: "file.tar.gz",
+			cancelled:       true,
+			expectError:     true,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -99,10 +274,18 @@ func TestExtractFile(t *testing.T) {
 				t.Fatal(err)
 			}
 			tempArchiveFilePath := tempDir + "/" + filepath.Base(tc.archiveFilePath)
-			wasExtracted, err := jkl.ExtractFile(tempArchiveFilePath)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.cancelled {
+				cancel()
+			}
+			wasExtracted, err := jkl.ExtractFile(ctx, tempArchiveFilePath, tc.extractOptions...)
 			if err != nil && !tc.expectError {
 				t.Fatal(err)
 			}
+			if err == nil && tc.expectError {
+				t.Fatal("an error is expected")
+			}
 			if tc.wasExtracted != wasExtracted {
 				t.Errorf("want wasExtracted to be %v, got %v", tc.wasExtracted, wasExtracted)
 			}
@@ -122,6 +305,15 @@ func TestExtractFile(t *testing.T) {
 			if !cmp.Equal(wantExtractedFiles, gotExtractedFiles) {
 				t.Fatalf("want vs. got files extracted: %s", cmp.Diff(wantExtractedFiles, gotExtractedFiles))
 			}
+			for _, executableFile := range tc.executableFiles {
+				stat, err := os.Stat(filepath.Join(tempDir, executableFile))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if stat.Mode().Perm()&0100 == 0 {
+					t.Errorf("want extracted file %s to be executable, got mode %v", executableFile, stat.Mode())
+				}
+			}
 		})
 	}
 }
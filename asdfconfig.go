@@ -2,7 +2,10 @@ package jkl
 
 import (
 	"bufio"
+	"errors"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -13,8 +16,9 @@ const (
 // asdfConfigSearchParameters holds the start and end directory
 // boundaries to use when searching for an ASDF configuration file.
 type asdfConfigSearchParameters struct {
-	startDir string // Name of the directory to begin searching for an ASDF config file
-	rootDir  string // Where to stop traversing parent directories while searching for an ASDF config file
+	startDir       string // Name of the directory to begin searching for an ASDF config file
+	rootDir        string // Where to stop traversing parent directories while searching for an ASDF config file
+	lookupCacheDir string // Where the results of searches are cached across processes, if set
 }
 
 // asdfConfigSearchOption is the functional options pattern for
@@ -37,11 +41,28 @@ func WithASDFConfigSearchRootDir(r string) asdfConfigSearchOption {
 	}
 }
 
+// withASDFConfigLookupCacheDir sets a directory where the results of
+// searching for ASDF configuration files are cached, for use by later
+// searches from the same start directory, including by other processes.
+func withASDFConfigLookupCacheDir(d string) asdfConfigSearchOption {
+	return func(p *asdfConfigSearchParameters) {
+		p.lookupCacheDir = d
+	}
+}
+
 // findASDFToolVersion traverses parent directories to find the desired
 // version for the specified tool, in the ASDF configuration file.
 // The WithASDFConfigSearch* functions can be used to specify th start and
 // stop (root) directory where config files should be consulted.
 func FindASDFToolVersion(toolName string, asdfConfigSearchOptions ...asdfConfigSearchOption) (toolVersion string, foundTool bool, err error) {
+	toolVersion, _, _, foundTool, err = findASDFToolVersionLine(toolName, asdfConfigSearchOptions...)
+	return toolVersion, foundTool, err
+}
+
+// findASDFToolVersionLine returns the desired version for the specified tool
+// like FindASDFToolVersion, including the path and line number of the ASDF
+// configuration file that specified the version.
+func findASDFToolVersionLine(toolName string, asdfConfigSearchOptions ...asdfConfigSearchOption) (toolVersion, filePath string, line int, foundTool bool, err error) {
 	searchParams := &asdfConfigSearchParameters{}
 	for _, option := range asdfConfigSearchOptions {
 		option(searchParams)
@@ -49,41 +70,80 @@ func FindASDFToolVersion(toolName string, asdfConfigSearchOptions ...asdfConfigS
 	if searchParams.startDir == "" {
 		currentDir, err := os.Getwd()
 		if err != nil {
-			return "", false, err
+			return "", "", 0, false, err
 		}
 		searchParams.startDir = currentDir
 	}
 	if searchParams.rootDir == "" {
 		searchParams.rootDir = "/"
 	}
+	if searchParams.lookupCacheDir != "" {
+		return findCachedASDFToolVersionLine(toolName, searchParams.lookupCacheDir, searchParams.startDir, searchParams.rootDir)
+	}
 	locations, err := listPathsByParent(ASDFConfigFileName, searchParams.startDir, searchParams.rootDir)
 	if err != nil {
-		return "", false, err
+		return "", "", 0, false, err
 	}
 	for _, location := range locations {
-		v, ok, err := getToolVersionFromASDFConfigFile(location+"/"+ASDFConfigFileName, toolName)
+		filePath = location + "/" + ASDFConfigFileName
+		v, line, ok, err := getToolVersionFromASDFConfigFile(filePath, toolName)
 		if err != nil {
-			return "", false, err
+			return "", "", 0, false, err
 		}
 		if ok {
-			return v, true, nil
+			return v, filePath, line, true, nil
 		}
 	}
-	return "", false, nil
+	return "", "", 0, false, nil
+}
+
+// asdfConfigFile holds the tool versions parsed from an ASDF configuration
+// file.
+type asdfConfigFile struct {
+	versions map[string]asdfToolVersion // by tool name
+}
+
+// asdfToolVersion is a tool version, and the line number where it was
+// specified in an ASDF configuration file.
+type asdfToolVersion struct {
+	version string
+	line    int
 }
 
 // getToolVersionFromASDFConfigFile parses an ASDF tool-versions configuration
-// file, returning the version for the specified tool, if found.
-func getToolVersionFromASDFConfigFile(filePath, toolName string) (toolVersion string, foundTool bool, err error) {
-	debugLog.Printf("Reading ASDF config file %s for %s version", filePath, toolName)
+// file, returning the version for the specified tool and the line number
+// where it was found.
+// Parsed files are not memoized in the process, as each run of a shim is a
+// new process which looks up the version of one tool. See
+// findCachedASDFToolVersionLine for the cache used across processes.
+func getToolVersionFromASDFConfigFile(filePath, toolName string) (toolVersion string, line int, foundTool bool, err error) {
+	configFile, err := parseASDFConfigFile(filePath)
+	if err != nil {
+		return "", 0, false, err
+	}
+	v, foundTool := configFile.versions[toolName]
+	if foundTool {
+		debugLog.Printf("Found version %s for %s in ASDF config file %s line %d", v.version, toolName, filePath, v.line)
+	}
+	return v.version, v.line, foundTool, nil
+}
+
+// parseASDFConfigFile reads the tool versions from an ASDF tool-versions
+// configuration file. If a tool is listed more than once, its first version
+// is used.
+func parseASDFConfigFile(filePath string) (asdfConfigFile, error) {
+	debugLog.Printf("Reading ASDF config file %s", filePath)
 	f, err := os.Open(filePath)
 	if err != nil {
-		return
+		return asdfConfigFile{}, err
 	}
 	defer f.Close()
+	configFile := asdfConfigFile{versions: make(map[string]asdfToolVersion)}
 	s := bufio.NewScanner(f)
 	s.Split(bufio.ScanLines)
+	var line int
 	for s.Scan() {
+		line++
 		fields := strings.Fields(s.Text())
 		if len(fields) == 0 {
 			continue
@@ -102,11 +162,77 @@ func getToolVersionFromASDFConfigFile(filePath, toolName string) (toolVersion st
 			debugLog.Printf("Too many tokens found in line: %q\n", s.Text())
 			continue
 		}
-		if fields[0] == toolName {
-			toolVersion = fields[1]
-			debugLog.Printf("Found version %s for %s in ASDF config file %s", toolVersion, toolName, filePath)
-			return toolVersion, true, nil
+		if _, ok := configFile.versions[fields[0]]; !ok {
+			configFile.versions[fields[0]] = asdfToolVersion{version: fields[1], line: line}
+		}
+	}
+	err = s.Err()
+	if err != nil {
+		return asdfConfigFile{}, err
+	}
+	return configFile, nil
+}
+
+// WriteASDFToolVersion sets the version of the specified tool in an
+// ASDF tool-versions configuration file, which is created if it does not
+// exist. An empty version removes the tool from the file.
+// Comments and the order of other lines are preserved, and an existing line
+// for the tool is updated in place.
+func WriteASDFToolVersion(filePath, toolName, version string) error {
+	b, err := os.ReadFile(filePath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	mode := fs.FileMode(0644)
+	if stat, err := os.Stat(filePath); err == nil {
+		mode = stat.Mode().Perm()
+	}
+	var lines []string
+	if len(b) > 0 {
+		lines = strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	}
+	newLines := make([]string, 0, len(lines)+1)
+	var updated bool
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != toolName {
+			newLines = append(newLines, line)
+			continue
+		}
+		if version != "" && !updated {
+			newLines = append(newLines, toolName+" "+version)
+			updated = true
 		}
+		// Other lines for the tool would be ignored, and are removed.
+	}
+	if version != "" && !updated {
+		newLines = append(newLines, toolName+" "+version)
+	}
+	content := strings.Join(newLines, "\n")
+	if len(newLines) > 0 {
+		content += "\n"
+	}
+	debugLog.Printf("writing version %q of %s to %s", version, toolName, filePath)
+	err = os.MkdirAll(filepath.Dir(filePath), 0700)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // fails harmlessly once renamed
+	_, err = f.WriteString(content)
+	if err == nil {
+		err = f.Chmod(mode)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
 	}
-	return "", false, nil
+	return os.Rename(f.Name(), filePath)
 }
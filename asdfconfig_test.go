@@ -120,3 +120,80 @@ func writeDirsAndFile(filePath, fileContent string) error {
 	}
 	return nil
 }
+
+func TestWriteASDFToolVersionRoundTrip(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		description    string
+		initialContent string // an empty string does not create the file
+		toolName       string
+		version        string // an empty string removes the tool
+		wantContent    string
+	}{
+		{
+			description: "new file",
+			toolName:    "app",
+			version:     "1.2.3",
+			wantContent: "app 1.2.3\n",
+		},
+		{
+			description:    "update in place preserving comments and order",
+			initialContent: "# Project tools\nfirst 1.0.0\n\napp 1.0.0 # old\nlast 2.0.0\n",
+			toolName:       "app",
+			version:        "1.2.3",
+			wantContent:    "# Project tools\nfirst 1.0.0\n\napp 1.2.3\nlast 2.0.0\n",
+		},
+		{
+			description:    "append to a file without a trailing newline",
+			initialContent: "# Project tools\napp-extra 1.0.0",
+			toolName:       "app",
+			version:        "1.2.3",
+			wantContent:    "# Project tools\napp-extra 1.0.0\napp 1.2.3\n",
+		},
+		{
+			description:    "duplicate lines which would be ignored are removed",
+			initialContent: "app 1.0.0\nother 1.0.0\napp 1.1.0\n",
+			toolName:       "app",
+			version:        "1.2.3",
+			wantContent:    "app 1.2.3\nother 1.0.0\n",
+		},
+		{
+			description:    "remove the tool",
+			initialContent: "# Project tools\napp 1.0.0\nother 1.0.0\n",
+			toolName:       "app",
+			wantContent:    "# Project tools\nother 1.0.0\n",
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			tempDir := t.TempDir()
+			filePath := filepath.Join(tempDir, jkl.ASDFConfigFileName)
+			if tc.initialContent != "" {
+				err := writeDirsAndFile(filePath, tc.initialContent)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := jkl.WriteASDFToolVersion(filePath, tc.toolName, tc.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotContent, err := os.ReadFile(filePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(gotContent) != tc.wantContent {
+				t.Fatalf("want file content %q, got %q", tc.wantContent, gotContent)
+			}
+			gotVersion, gotFound, err := jkl.FindASDFToolVersion(tc.toolName, jkl.WithASDFConfigSearchStartDir(tempDir), jkl.WithASDFConfigSearchRootDir(tempDir))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotFound != (tc.version != "") || gotVersion != tc.version {
+				t.Fatalf("want to read back version %q, got %q (found=%v)", tc.version, gotVersion, gotFound)
+			}
+		})
+	}
+}
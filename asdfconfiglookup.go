@@ -0,0 +1,394 @@
+package jkl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// asdfConfigLookupSubDir is the directory, within the installs directory,
+// where lookups of ASDF configuration files are cached.
+const asdfConfigLookupSubDir = ".lookups"
+
+// asdfConfigLookupHeader is the first line of a cached lookup, which is
+// changed if the format changes.
+const asdfConfigLookupHeader = "jkl-lookup v1"
+
+// maxASDFConfigLookups is the number of cached lookups above which the oldest
+// are removed.
+const maxASDFConfigLookups = 256
+
+// asdfConfigLookupRacyWindow is how recently a directory or ASDF
+// configuration file may have been modified, for its modification time to not
+// be relied upon by a cached lookup. Filesystem timestamps may be too coarse
+// to show that it was modified again soon after it was read.
+const asdfConfigLookupRacyWindow = 2 * time.Second
+
+// asdfConfigLookup is the result of searching for ASDF configuration files
+// from a start directory up to a root directory. It is cached across runs of
+// shims, and is valid as long as no directory it searched has changed, and no
+// configuration file it read has been modified.
+type asdfConfigLookup struct {
+	startDir string
+	rootDir  string
+	dirs     []asdfConfigLookupDir // searched directories, from the start to the root directory
+}
+
+// asdfConfigLookupDir is a directory searched by a lookup. Files added to or
+// removed from the directory, including a configuration file or a symlink
+// replacing a sub-directory, change its modification time.
+type asdfConfigLookupDir struct {
+	path    string
+	modTime int64 // in nanoseconds since the Unix epoch
+	recent  bool  // modified too recently for its modification time to be relied upon
+	file    *asdfConfigLookupFile
+}
+
+// asdfConfigLookupFile is an ASDF configuration file read by a lookup.
+type asdfConfigLookupFile struct {
+	path    string
+	modTime int64 // in nanoseconds since the Unix epoch
+	size    int64
+	config  asdfConfigFile
+}
+
+// findCachedASDFToolVersionLine returns the desired version for the
+// specified tool like findASDFToolVersionLine, using a lookup cached in the
+// specified directory if it is still valid, and otherwise searching and
+// caching the result.
+// Lookups are only cached for start and root directories which do not
+// include symlinks, so a cached lookup can be validated without evaluating
+// symlinks.
+// Errors using the cache are only logged, as the search can always be
+// repeated.
+func findCachedASDFToolVersionLine(toolName, cacheDir, startDir, rootDir string) (toolVersion, filePath string, line int, foundTool bool, err error) {
+	entryPath := asdfConfigLookupPath(cacheDir, startDir, rootDir)
+	lookup, ok := loadASDFConfigLookup(entryPath, startDir, rootDir)
+	if !ok {
+		// Evaluating symlinks matches the directories searched by
+		// listPathsByParent.
+		resolvedStartDir, _ := filepath.EvalSymlinks(startDir)
+		resolvedRootDir, _ := filepath.EvalSymlinks(rootDir)
+		var cacheable bool
+		lookup, cacheable, err = newASDFConfigLookup(resolvedStartDir, resolvedRootDir)
+		if err != nil {
+			return "", "", 0, false, err
+		}
+		if resolvedStartDir != startDir || resolvedRootDir != rootDir {
+			debugLog.Printf("not caching the lookup of ASDF config files from %s, as it includes symlinks", startDir)
+			cacheable = false
+		}
+		if cacheable {
+			err = storeASDFConfigLookup(entryPath, lookup)
+			if err != nil {
+				debugLog.Printf("cannot cache the lookup of ASDF config files from %s in %s: %v", startDir, entryPath, err)
+			}
+		}
+	}
+	for _, dir := range lookup.dirs {
+		if dir.file == nil {
+			continue
+		}
+		v, ok := dir.file.config.versions[toolName]
+		if ok {
+			debugLog.Printf("Found version %s for %s in ASDF config file %s line %d", v.version, toolName, dir.file.path, v.line)
+			return v.version, dir.file.path, v.line, true, nil
+		}
+	}
+	return "", "", 0, false, nil
+}
+
+// asdfConfigLookupPath returns the file where the lookup from the specified
+// start directory to the root directory is cached.
+func asdfConfigLookupPath(cacheDir, startDir, rootDir string) string {
+	keyHash := sha256.Sum256([]byte(startDir + "\x00" + rootDir))
+	return filepath.Join(cacheDir, hex.EncodeToString(keyHash[:]))
+}
+
+// loadASDFConfigLookup returns the cached lookup from the specified start
+// directory to the root directory, if it is still valid.
+// Each searched directory is checked to still be a directory rather than a
+// symlink, and for a configuration file only if the directory has changed or
+// was modified recently. Configuration files which were read are checked for
+// modifications.
+func loadASDFConfigLookup(entryPath, startDir, rootDir string) (lookup asdfConfigLookup, ok bool) {
+	b, err := os.ReadFile(entryPath)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			debugLog.Printf("cannot read the cached lookup of ASDF config files %s: %v", entryPath, err)
+		}
+		return asdfConfigLookup{}, false
+	}
+	lookup, err = decodeASDFConfigLookup(string(b))
+	if err != nil {
+		debugLog.Printf("invalid cached lookup of ASDF config files %s: %v", entryPath, err)
+		return asdfConfigLookup{}, false
+	}
+	if lookup.startDir != startDir || lookup.rootDir != rootDir {
+		return asdfConfigLookup{}, false
+	}
+	for _, dir := range lookup.dirs {
+		dirInfo, err := os.Lstat(dir.path)
+		if err != nil || !dirInfo.IsDir() {
+			debugLog.Printf("the cached lookup of ASDF config files from %s is out of date, %s is no longer a directory", startDir, dir.path)
+			return asdfConfigLookup{}, false
+		}
+		if dir.file != nil {
+			info, err := os.Stat(dir.file.path)
+			if err != nil || info.ModTime().UnixNano() != dir.file.modTime || info.Size() != dir.file.size {
+				debugLog.Printf("the cached lookup of ASDF config files from %s is out of date, %s has changed", startDir, dir.file.path)
+				return asdfConfigLookup{}, false
+			}
+			continue
+		}
+		if !dir.recent && dirInfo.ModTime().UnixNano() == dir.modTime {
+			continue
+		}
+		filePath := filepath.Join(dir.path, ASDFConfigFileName)
+		_, err = os.Stat(filePath)
+		if !errors.Is(err, fs.ErrNotExist) {
+			debugLog.Printf("the cached lookup of ASDF config files from %s is out of date, %s may have been added", startDir, filePath)
+			return asdfConfigLookup{}, false
+		}
+	}
+	debugLog.Printf("using the cached lookup of ASDF config files from %s", startDir)
+	return lookup, true
+}
+
+// newASDFConfigLookup searches for and parses ASDF configuration files from
+// the start directory up to the root directory. The lookup is not cacheable
+// if a file it read was modified too recently for a later modification to be
+// noticed.
+func newASDFConfigLookup(startDir, rootDir string) (lookup asdfConfigLookup, cacheable bool, err error) {
+	debugLog.Printf("Searching for ASDF config files from %q to %q", startDir, rootDir)
+	lookup = asdfConfigLookup{
+		startDir: startDir,
+		rootDir:  rootDir,
+	}
+	cacheable = true
+	racyTime := time.Now().Add(-asdfConfigLookupRacyWindow)
+	dirPath := startDir
+	for {
+		// Each directory and file is stat'ed before it is searched or read,
+		// so a change while it is searched or read invalidates the lookup.
+		dirInfo, err := os.Lstat(dirPath)
+		if err != nil {
+			return asdfConfigLookup{}, false, err
+		}
+		dir := asdfConfigLookupDir{
+			path:    dirPath,
+			modTime: dirInfo.ModTime().UnixNano(),
+			recent:  dirInfo.ModTime().After(racyTime),
+		}
+		filePath := filepath.Join(dirPath, ASDFConfigFileName)
+		info, err := os.Stat(filePath)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return asdfConfigLookup{}, false, err
+		}
+		if err == nil {
+			configFile, err := parseASDFConfigFile(filePath)
+			if err != nil {
+				return asdfConfigLookup{}, false, err
+			}
+			dir.file = &asdfConfigLookupFile{
+				path:    filePath,
+				modTime: info.ModTime().UnixNano(),
+				size:    info.Size(),
+				config:  configFile,
+			}
+			if info.ModTime().After(racyTime) {
+				debugLog.Printf("not caching the lookup of ASDF config files from %s, as %s was modified recently", startDir, filePath)
+				cacheable = false
+			}
+		}
+		lookup.dirs = append(lookup.dirs, dir)
+		parentDir := filepath.Dir(dirPath)
+		if dirPath == rootDir || parentDir == dirPath {
+			break
+		}
+		dirPath = parentDir
+	}
+	return lookup, cacheable, nil
+}
+
+// encode returns the lookup in the format of a cached lookup, which is
+// quicker to decode than JSON:
+//
+//	jkl-lookup v1
+//	start "<start directory>"
+//	root "<root directory>"
+//	dir <modification time> <recent: 0 or 1> "<directory>"
+//	file <modification time> <size> "<configuration file in the directory>"
+//	version <line number> <tool> <version>
+//
+// A dir line is written for each searched directory, followed by a file line
+// if it has a configuration file, followed by a version line for each tool
+// in that file.
+func (l asdfConfigLookup) encode() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\nstart %q\nroot %q\n", asdfConfigLookupHeader, l.startDir, l.rootDir)
+	for _, dir := range l.dirs {
+		var recent int
+		if dir.recent {
+			recent = 1
+		}
+		fmt.Fprintf(&b, "dir %d %d %q\n", dir.modTime, recent, dir.path)
+		if dir.file == nil {
+			continue
+		}
+		fmt.Fprintf(&b, "file %d %d %q\n", dir.file.modTime, dir.file.size, dir.file.path)
+		toolNames := make([]string, 0, len(dir.file.config.versions))
+		for toolName := range dir.file.config.versions {
+			toolNames = append(toolNames, toolName)
+		}
+		sort.Strings(toolNames)
+		for _, toolName := range toolNames {
+			v := dir.file.config.versions[toolName]
+			fmt.Fprintf(&b, "version %d %s %s\n", v.line, toolName, v.version)
+		}
+	}
+	return b.String()
+}
+
+// decodeASDFConfigLookup parses a cached lookup, see
+// asdfConfigLookup.encode() for its format.
+func decodeASDFConfigLookup(s string) (lookup asdfConfigLookup, err error) {
+	header, s, _ := strings.Cut(s, "\n")
+	if header != asdfConfigLookupHeader {
+		return asdfConfigLookup{}, fmt.Errorf("unknown format %q", header)
+	}
+	var file *asdfConfigLookupFile
+	for lineNum := 2; s != ""; lineNum++ {
+		var line string
+		line, s, _ = strings.Cut(s, "\n")
+		kind, fields, _ := strings.Cut(line, " ")
+		switch kind {
+		case "start":
+			lookup.startDir, err = strconv.Unquote(fields)
+		case "root":
+			lookup.rootDir, err = strconv.Unquote(fields)
+		case "dir":
+			var dir asdfConfigLookupDir
+			var recent string
+			dir.modTime, recent, dir.path, err = decodeASDFConfigLookupStat(fields)
+			dir.recent = recent == "1"
+			lookup.dirs = append(lookup.dirs, dir)
+			file = nil
+		case "file":
+			if len(lookup.dirs) == 0 {
+				return asdfConfigLookup{}, fmt.Errorf("line %d: a file before its directory", lineNum)
+			}
+			file = &asdfConfigLookupFile{config: asdfConfigFile{versions: make(map[string]asdfToolVersion)}}
+			var size string
+			file.modTime, size, file.path, err = decodeASDFConfigLookupStat(fields)
+			if err == nil {
+				file.size, err = strconv.ParseInt(size, 10, 64)
+			}
+			lookup.dirs[len(lookup.dirs)-1].file = file
+		case "version":
+			if file == nil {
+				return asdfConfigLookup{}, fmt.Errorf("line %d: a version before its file", lineNum)
+			}
+			versionFields := strings.Fields(fields)
+			if len(versionFields) != 3 {
+				return asdfConfigLookup{}, fmt.Errorf("line %d: want a line number, tool, and version", lineNum)
+			}
+			var v asdfToolVersion
+			v.line, err = strconv.Atoi(versionFields[0])
+			v.version = versionFields[2]
+			file.config.versions[versionFields[1]] = v
+		default:
+			return asdfConfigLookup{}, fmt.Errorf("line %d: unknown record %q", lineNum, kind)
+		}
+		if err != nil {
+			return asdfConfigLookup{}, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+	}
+	return lookup, nil
+}
+
+// decodeASDFConfigLookupStat parses the fields of a dir or file line of a
+// cached lookup: a modification time, another field, and a quoted path.
+func decodeASDFConfigLookupStat(fields string) (modTime int64, field, path string, err error) {
+	modTimeField, fields, _ := strings.Cut(fields, " ")
+	field, quotedPath, _ := strings.Cut(fields, " ")
+	modTime, err = strconv.ParseInt(modTimeField, 10, 64)
+	if err != nil {
+		return 0, "", "", err
+	}
+	path, err = strconv.Unquote(quotedPath)
+	if err != nil {
+		return 0, "", "", err
+	}
+	return modTime, field, path, nil
+}
+
+// storeASDFConfigLookup atomically caches the lookup, removing the oldest
+// cached lookups if there are too many.
+func storeASDFConfigLookup(entryPath string, lookup asdfConfigLookup) error {
+	cacheDir := filepath.Dir(entryPath)
+	err := os.MkdirAll(cacheDir, 0700)
+	if err != nil {
+		return err
+	}
+	tempFile, err := os.CreateTemp(cacheDir, "."+filepath.Base(entryPath)+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+	_, err = tempFile.WriteString(lookup.encode())
+	closeErr := tempFile.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	err = os.Rename(tempFile.Name(), entryPath)
+	if err != nil {
+		return err
+	}
+	debugLog.Printf("cached the lookup of ASDF config files from %s in %s", lookup.startDir, entryPath)
+	return pruneASDFConfigLookups(cacheDir)
+}
+
+// pruneASDFConfigLookups removes the oldest half of the cached lookups, if
+// there are more than maxASDFConfigLookups.
+func pruneASDFConfigLookups(cacheDir string) error {
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		return err
+	}
+	if len(entries) <= maxASDFConfigLookups {
+		return nil
+	}
+	modTimes := make(map[string]time.Time, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			// The entry may have been removed by another process.
+			continue
+		}
+		modTimes[entry.Name()] = info.ModTime()
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return modTimes[entries[i].Name()].Before(modTimes[entries[j].Name()])
+	})
+	oldEntries := entries[:len(entries)-maxASDFConfigLookups/2]
+	debugLog.Printf("removing the oldest %d cached lookups of ASDF config files from %s", len(oldEntries), cacheDir)
+	for _, entry := range oldEntries {
+		err = os.Remove(filepath.Join(cacheDir, entry.Name()))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("while removing old cached lookups of ASDF config files: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package jkl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// sourcePath returns the path of the file which records the provider and
+// source that the tool was installed from, as in a tool-specification such
+// as github:<owner>/<repository>. This lets shims install other versions of
+// the tool.
+func (t managedTool) sourcePath() string {
+	return filepath.Join(t.jkl.installsDir, "."+t.name+".source")
+}
+
+// recordSource records the provider and source that the tool was installed
+// from. The caller should hold the lock for the tool.
+// Errors are only logged, as the source is only needed to install versions
+// of the tool automatically.
+func (t managedTool) recordSource(providerAndSource string) {
+	sourcePath := t.sourcePath()
+	err := os.WriteFile(sourcePath, []byte(providerAndSource+"\n"), 0600)
+	if err != nil {
+		debugLog.Printf("cannot record the source of %s in %s: %v", t.name, sourcePath, err)
+		return
+	}
+	debugLog.Printf("recorded the source %s of %s in %s", providerAndSource, t.name, sourcePath)
+}
+
+// source returns the provider and source that the tool was last installed
+// from.
+func (t managedTool) source() (providerAndSource string, found bool, err error) {
+	b, err := os.ReadFile(t.sourcePath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	providerAndSource = strings.TrimSpace(string(b))
+	return providerAndSource, providerAndSource != "", nil
+}
+
+// removeSource removes the recorded source of the tool, once all of its
+// versions are uninstalled.
+func (t managedTool) removeSource() {
+	err := os.Remove(t.sourcePath())
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		debugLog.Printf("cannot remove the source of %s: %v", t.name, err)
+	}
+}
+
+// AutoInstallToolCommandPath returns the path to the installed version of the
+// tool that its shim runs, like ToolCommandPath. If that version is not
+// installed and automatic installation is enabled, it is first installed from
+// the provider and source that the tool was previously installed from.
+// Progress is written to output.
+func (j JKL) AutoInstallToolCommandPath(ctx context.Context, toolName string, output io.Writer) (string, error) {
+	tool := j.getManagedTool(toolName)
+	commandPath, err := tool.commandPath()
+	var notInstalledErr versionNotInstalledError
+	if !errors.As(err, &notInstalledErr) || !j.autoInstall {
+		return commandPath, err
+	}
+	commandPath, installErr := j.autoInstallVersion(ctx, tool, notInstalledErr.version, fmt.Sprintf("as specified by %s", notInstalledErr.source), output)
+	if installErr != nil {
+		return "", fmt.Errorf("%v, %v", err, installErr)
+	}
+	return commandPath, nil
+}
+
+// autoInstallVersion installs the specified version of the tool from the
+// provider and source that the tool was previously installed from,
+// returning the path to the installed binary. Progress is written to output,
+// including the optional reason the version is installed.
+func (j JKL) autoInstallVersion(ctx context.Context, tool *managedTool, version, reason string, output io.Writer) (string, error) {
+	providerAndSource, found, err := tool.source()
+	if err != nil {
+		return "", fmt.Errorf("cannot determine where to install it from: %v", err)
+	}
+	if !found {
+		return "", fmt.Errorf("it cannot be installed automatically because the provider of %s is not known", tool.name)
+	}
+	if reason != "" {
+		reason = ", " + reason
+	}
+	fmt.Fprintf(output, "%s: installing %s %s from %s%s\n", callMeProgName, tool.name, version, providerAndSource, reason)
+	toolSpec, err := j.installToolSpec(ctx, providerAndSource+":"+version)
+	if err != nil {
+		return "", fmt.Errorf("while installing %s %s automatically: %v", tool.name, version, err)
+	}
+	commandPath, found, err := tool.path(toolSpec.version)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", fmt.Errorf("installing %s from %s resulted in %s %s instead", version, providerAndSource, toolSpec.name, toolSpec.version)
+	}
+	fmt.Fprintf(output, "%s: installed %s %s\n", callMeProgName, tool.name, toolSpec.version)
+	return commandPath, nil
+}
+
+// autoInstallForShim installs the version of the tool that its shim runs, if
+// automatic installation is enabled, returning the path to the installed
+// binary. Shims skip reading the configuration file and setting up HTTP, so a
+// complete JKL is constructed first.
+// An interrupt or termination signal stops the installation, which then
+// removes partial work.
+func autoInstallForShim(toolName string, output *os.File) (string, error) {
+	j, err := NewJKL()
+	if err != nil {
+		return "", err
+	}
+	if isTerminal(output) {
+		j.downloadProgress = newTerminalProgressBar(output)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return j.AutoInstallToolCommandPath(ctx, toolName, output)
+}
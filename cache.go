@@ -0,0 +1,377 @@
+package jkl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	downloadCacheSubDir       = "downloads"
+	downloadCacheMetadataFile = "metadata.json"
+)
+
+// DownloadCache stores downloaded files by their URL, along with their
+// SHA-256 digest, so a file can be reused instead of downloaded again.
+type DownloadCache struct {
+	dir string
+}
+
+// NewDownloadCache returns a DownloadCache which stores files in the
+// specified directory. The directory is created when the first file is
+// stored.
+func NewDownloadCache(dir string) (*DownloadCache, error) {
+	if dir == "" {
+		return nil, errors.New("the cache directory cannot be empty")
+	}
+	return &DownloadCache{dir: dir}, nil
+}
+
+// CachedDownload describes a file in a DownloadCache.
+type CachedDownload struct {
+	URL        string    `json:"url"`
+	FileName   string    `json:"file_name"`
+	SHA256     string    `json:"sha256"`
+	Size       int64     `json:"size"`
+	Downloaded time.Time `json:"downloaded"`
+	LastUsed   time.Time `json:"last_used"`
+	dir        string    // the cache entry directory
+}
+
+// Path returns the full path to the cached file.
+func (d CachedDownload) Path() string {
+	return filepath.Join(d.dir, d.FileName)
+}
+
+// entryDir returns the directory where the download for the specified URL
+// is stored.
+func (c DownloadCache) entryDir(URL string) string {
+	URLHash := sha256.Sum256([]byte(URL))
+	return filepath.Join(c.dir, downloadCacheSubDir, hex.EncodeToString(URLHash[:]))
+}
+
+// readCacheEntry reads the metadata of the cache entry in the specified
+// directory.
+func readCacheEntry(entryDir string) (CachedDownload, error) {
+	b, err := os.ReadFile(filepath.Join(entryDir, downloadCacheMetadataFile))
+	if err != nil {
+		return CachedDownload{}, err
+	}
+	var d CachedDownload
+	err = json.Unmarshal(b, &d)
+	if err != nil {
+		return CachedDownload{}, fmt.Errorf("invalid cache metadata in %s: %v", entryDir, err)
+	}
+	if d.FileName == "" || filepath.Base(d.FileName) != d.FileName {
+		return CachedDownload{}, fmt.Errorf("invalid file name %q in cache metadata in %s", d.FileName, entryDir)
+	}
+	d.dir = entryDir
+	return d, nil
+}
+
+// writeMetadata atomically writes the metadata of the cache entry.
+func (d CachedDownload) writeMetadata() error {
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	tempFile, err := os.CreateTemp(d.dir, "."+downloadCacheMetadataFile+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+	_, err = tempFile.Write(b)
+	if err == nil {
+		err = tempFile.Sync()
+	}
+	closeErr := tempFile.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	return os.Rename(tempFile.Name(), filepath.Join(d.dir, downloadCacheMetadataFile))
+}
+
+// fileSHA256 returns the hex-encoded SHA-256 digest of the specified file.
+func fileSHA256(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Get returns the cached download for the specified URL. A cached file
+// which no longer matches its recorded digest is removed, and reported as
+// not found.
+func (c DownloadCache) Get(URL string) (download CachedDownload, found bool, err error) {
+	entryDir := c.entryDir(URL)
+	download, err = readCacheEntry(entryDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		debugLog.Printf("%s is not in the download cache", URL)
+		return CachedDownload{}, false, nil
+	}
+	if err != nil {
+		return CachedDownload{}, false, err
+	}
+	gotDigest, err := fileSHA256(download.Path())
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return CachedDownload{}, false, err
+	}
+	if download.URL != URL || gotDigest != download.SHA256 {
+		debugLog.Printf("removing the cached download of %s from %s, as it is incomplete or does not match its SHA256 digest %s", URL, entryDir, download.SHA256)
+		err = os.RemoveAll(entryDir)
+		if err != nil {
+			return CachedDownload{}, false, err
+		}
+		return CachedDownload{}, false, nil
+	}
+	download.LastUsed = time.Now()
+	err = download.writeMetadata()
+	if err != nil {
+		debugLog.Printf("cannot update the last-used time of the cached download %s: %v", download.Path(), err)
+	}
+	debugLog.Printf("using cached download %s for %s", download.Path(), URL)
+	return download, true, nil
+}
+
+// Put stores the content of the io.Reader as the download of the specified
+// URL, named fileName.
+func (c DownloadCache) Put(URL, fileName string, r io.Reader) (CachedDownload, error) {
+	tempFile, err := c.createTemp(URL, fileName)
+	if err != nil {
+		return CachedDownload{}, err
+	}
+	defer os.Remove(tempFile.Name())
+	_, err = io.Copy(tempFile, r)
+	if err == nil {
+		err = tempFile.Sync()
+	}
+	closeErr := tempFile.Close()
+	if err != nil {
+		return CachedDownload{}, err
+	}
+	if closeErr != nil {
+		return CachedDownload{}, closeErr
+	}
+	return c.putFile(URL, fileName, tempFile.Name())
+}
+
+// createTemp creates a temporary file in the cache entry for the specified
+// URL, which is stored as the download of that URL by putFile.
+func (c DownloadCache) createTemp(URL, fileName string) (*os.File, error) {
+	if fileName == "" || filepath.Base(fileName) != fileName {
+		return nil, fmt.Errorf("invalid file name %q for the download cache", fileName)
+	}
+	entryDir := c.entryDir(URL)
+	err := os.MkdirAll(entryDir, 0700)
+	if err != nil {
+		return nil, err
+	}
+	return os.CreateTemp(entryDir, "."+fileName+"-")
+}
+
+// putFile renames the temporary file created by createTemp, storing it as
+// the download of the specified URL, named fileName.
+func (c DownloadCache) putFile(URL, fileName, tempFilePath string) (CachedDownload, error) {
+	digest, err := fileSHA256(tempFilePath)
+	if err != nil {
+		return CachedDownload{}, err
+	}
+	stat, err := os.Stat(tempFilePath)
+	if err != nil {
+		return CachedDownload{}, err
+	}
+	download := CachedDownload{
+		URL:        URL,
+		FileName:   fileName,
+		SHA256:     digest,
+		Size:       stat.Size(),
+		Downloaded: time.Now(),
+		dir:        c.entryDir(URL),
+	}
+	download.LastUsed = download.Downloaded
+	err = os.Rename(tempFilePath, download.Path())
+	if err != nil {
+		return CachedDownload{}, err
+	}
+	err = download.writeMetadata()
+	if err != nil {
+		return CachedDownload{}, err
+	}
+	debugLog.Printf("cached download of %s as %s, SHA256 %s", URL, download.Path(), download.SHA256)
+	return download, nil
+}
+
+// List returns the cached downloads, most recently used first.
+func (c DownloadCache) List() ([]CachedDownload, error) {
+	entries, err := os.ReadDir(filepath.Join(c.dir, downloadCacheSubDir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return []CachedDownload{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	downloads := make([]CachedDownload, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		download, err := readCacheEntry(filepath.Join(c.dir, downloadCacheSubDir, entry.Name()))
+		if err != nil {
+			debugLog.Printf("ignoring cache entry %s: %v", entry.Name(), err)
+			continue
+		}
+		downloads = append(downloads, download)
+	}
+	sort.Slice(downloads, func(i, j int) bool {
+		return downloads[i].LastUsed.After(downloads[j].LastUsed)
+	})
+	return downloads, nil
+}
+
+// Remove deletes the specified download from the cache.
+func (c DownloadCache) Remove(download CachedDownload) error {
+	debugLog.Printf("removing cached download %s", download.Path())
+	return os.RemoveAll(download.dir)
+}
+
+// Prune removes cached downloads which have not been used within maxAge,
+// then removes the least recently used downloads until the cache is no
+// larger than maxSize. A maxAge or maxSize of zero is not enforced.
+func (c DownloadCache) Prune(maxAge time.Duration, maxSize int64) (removed []CachedDownload, err error) {
+	downloads, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+	var totalSize int64
+	for _, download := range downloads {
+		totalSize += download.Size
+	}
+	// Iterate least recently used first.
+	for i := len(downloads) - 1; i >= 0; i-- {
+		download := downloads[i]
+		tooOld := maxAge > 0 && time.Since(download.LastUsed) > maxAge
+		tooBig := maxSize > 0 && totalSize > maxSize
+		if !tooOld && !tooBig {
+			continue
+		}
+		err := c.Remove(download)
+		if err != nil {
+			return removed, err
+		}
+		totalSize -= download.Size
+		removed = append(removed, download)
+	}
+	return removed, nil
+}
+
+// Clear removes all cached downloads.
+func (c DownloadCache) Clear() error {
+	debugLog.Printf("clearing the download cache %s", c.dir)
+	return os.RemoveAll(filepath.Join(c.dir, downloadCacheSubDir))
+}
+
+// formatByteSize returns a human-readable size, such as 1.5MB.
+func formatByteSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// parseByteSize parses a size such as 500MB or 2GB, which uses powers of
+// 1024. A size without a unit is in bytes.
+func parseByteSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	multipliers := []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"TB", 1 << 40},
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"T", 1 << 40},
+		{"G", 1 << 30},
+		{"M", 1 << 20},
+		{"K", 1 << 10},
+		{"B", 1},
+	}
+	multiplier := int64(1)
+	for _, m := range multipliers {
+		if strings.HasSuffix(s, m.suffix) {
+			multiplier = m.multiplier
+			s = strings.TrimSpace(strings.TrimSuffix(s, m.suffix))
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q, specify a number optionally followed by KB, MB, GB, or TB", s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+func (j JKL) displayDownloadCache(output io.Writer) error {
+	downloads, err := j.downloadCache().List()
+	if err != nil {
+		return fmt.Errorf("cannot list the download cache: %v", err)
+	}
+	if len(downloads) == 0 {
+		fmt.Fprintf(output, "The download cache %s is empty\n", j.cacheDir)
+		return nil
+	}
+	w := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SIZE\tLAST USED\tFILE\tURL")
+	var totalSize int64
+	for _, d := range downloads {
+		totalSize += d.Size
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", formatByteSize(d.Size), d.LastUsed.Format(time.DateTime), d.FileName, d.URL)
+	}
+	err = w.Flush()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(output, "%d downloads totaling %s in %s\n", len(downloads), formatByteSize(totalSize), j.cacheDir)
+	return nil
+}
+
+func (j JKL) pruneDownloadCache(output io.Writer, maxAge time.Duration, maxSize int64) error {
+	removed, err := j.downloadCache().Prune(maxAge, maxSize)
+	for _, d := range removed {
+		fmt.Fprintf(output, "removed %s (%s)\n", d.FileName, formatByteSize(d.Size))
+	}
+	if err != nil {
+		return fmt.Errorf("cannot prune the download cache: %v", err)
+	}
+	if len(removed) == 0 {
+		fmt.Fprintln(output, "No cached downloads needed to be removed")
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package jkl_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ivanfetch/jkl"
+)
+
+func TestDownloadCacheGetAndPut(t *testing.T) {
+	t.Parallel()
+	cache, err := jkl.NewDownloadCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	const URL = "https://example.com/releases/tool_1.0.0.tar.gz"
+	_, found, err := cache.Get(URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found {
+		t.Fatal("want the download to not be found in an empty cache")
+	}
+	_, err = cache.Put(URL, "tool_1.0.0.tar.gz", strings.NewReader("tool content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, found, err := cache.Get(URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found {
+		t.Fatal("want the download to be found after it was stored")
+	}
+	wantSHA256 := "ffe754e527681e1200c26dfba0c47ab8819b03d34452fbfa0b70b2c44c73e464"
+	if got.SHA256 != wantSHA256 {
+		t.Errorf("want SHA256 %s, got %s", wantSHA256, got.SHA256)
+	}
+	if got.URL != URL || got.FileName != "tool_1.0.0.tar.gz" || got.Size != int64(len("tool content")) {
+		t.Errorf("unexpected cached download %+v", got)
+	}
+	b, err := os.ReadFile(got.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "tool content" {
+		t.Fatalf("want cached content %q, got %q", "tool content", b)
+	}
+	// A modified file no longer matches its digest.
+	err = os.WriteFile(got.Path(), []byte("tampered"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, found, err = cache.Get(URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found {
+		t.Fatal("want a download which does not match its digest to not be found")
+	}
+	downloads, err := cache.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(downloads) != 0 {
+		t.Fatalf("want the modified download to be removed from the cache, got %v", downloads)
+	}
+}
+
+func TestDownloadCachePruneAndClear(t *testing.T) {
+	t.Parallel()
+	cache, err := jkl.NewDownloadCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"one", "two", "three"} {
+		_, err := cache.Put("https://example.com/"+name, name, strings.NewReader(strings.Repeat("x", 100)))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Use the first download, so the second is the least recently used.
+	_, _, err = cache.Get("https://example.com/one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	removed, err := cache.Prune(0, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0].FileName != "two" {
+		t.Fatalf("want the least recently used download to be pruned, got %+v", removed)
+	}
+	downloads, err := cache.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(downloads) != 2 || downloads[0].FileName != "one" || downloads[1].FileName != "three" {
+		t.Fatalf("want downloads one and three, most recently used first, got %+v", downloads)
+	}
+	time.Sleep(10 * time.Millisecond)
+	removed, err = cache.Prune(5*time.Millisecond, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 2 {
+		t.Fatalf("want both downloads to be pruned by age, got %+v", removed)
+	}
+	_, err = cache.Put("https://example.com/four", "four", strings.NewReader("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cache.Clear()
+	if err != nil {
+		t.Fatal(err)
+	}
+	downloads, err = cache.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(downloads) != 0 {
+		t.Fatalf("want an empty cache after clearing, got %+v", downloads)
+	}
+}
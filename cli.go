@@ -1,10 +1,15 @@
 package jkl
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -12,7 +17,7 @@ import (
 // Main is an exported function that calls the same entrypoint as the jkl
 // binary. This is used by tests, to run jkl. See script_test.go.
 func Main() (exitCode int) {
-	err := RunCLI(os.Args, os.Stdout, os.Stderr)
+	err := RunCLI(context.Background(), os.Args, os.Stdout, os.Stderr)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		return 1
@@ -22,18 +27,33 @@ func Main() (exitCode int) {
 
 // RunCLI determines how this binary was run, and either calls RunShim() or
 // processes JKL commands and arguments.
-func RunCLI(args []string, output, errOutput io.Writer) error {
-	j, err := NewJKL()
-	if err != nil {
-		return err
-	}
+// An interrupt or termination signal cancels the context used by jkl
+// commands, which then remove partial work such as temporary files.
+func RunCLI(ctx context.Context, args []string, output, errOutput io.Writer) error {
 	calledProgName := filepath.Base(args[0])
 	if calledProgName != callMeProgName { // Running as a shim
+		j, err := newShimJKL()
+		if err != nil {
+			return err
+		}
 		return j.RunShim(args)
 	}
+	// An invalid configuration file is reported by the doctor command, and
+	// returned by other commands before they run.
+	j, err := NewJKL(WithInvalidConfigAllowed(true))
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		// A second signal terminates jkl without waiting for cleanup.
+		stop()
+	}()
 
 	// Cobra commands are defined here to inharit the JKL instance.
-	var debugFlagEnabled bool
+	var debugFlagEnabled, offlineFlagEnabled bool
 	var rootCmd = &cobra.Command{
 		Use:           "jkl",
 		Short:         "A command-line tool version manager",
@@ -43,7 +63,24 @@ func RunCLI(args []string, output, errOutput io.Writer) error {
 			if os.Getenv("JKL_DEBUG") != "" || debugFlagEnabled {
 				EnableDebugOutput()
 			}
-			err := j.displayPreFlightCheck(cmd.OutOrStdout())
+			if j.configErr != nil && cmd.Name() != "doctor" {
+				cmd.SilenceUsage = true
+				return j.configErr
+			}
+			if offlineFlagEnabled {
+				j.offline = true
+			}
+			if isTerminal(os.Stderr) {
+				j.downloadProgress = newTerminalProgressBar(os.Stderr)
+			}
+			preFlightOutput := cmd.OutOrStdout()
+			switch cmd.Name() {
+			case "env", "hook", "doctor": // these add or check the shims directory in PATH
+				return nil
+			case "exec", "use": // output is used by an executed tool or the shell
+				preFlightOutput = cmd.ErrOrStderr()
+			}
+			err := j.displayPreFlightCheck(preFlightOutput)
 			return err
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -52,6 +89,7 @@ func RunCLI(args []string, output, errOutput io.Writer) error {
 		},
 	}
 	rootCmd.CompletionOptions.DisableDefaultCmd = true // Until completion behavior is tested
+	rootCmd.PersistentFlags().BoolVar(&offlineFlagEnabled, "offline", false, "Only use previously downloaded tools and release listings, without accessing the network (also enabled by setting the JKL_OFFLINE environment variable to any value).")
 	rootCmd.PersistentFlags().BoolVarP(&debugFlagEnabled, "debug", "D", false, "Enable debug output (also enabled by setting the JKL_DEBUG environment variable to any value).")
 
 	var versionOnly, commitOnly bool
@@ -70,13 +108,17 @@ func RunCLI(args []string, output, errOutput io.Writer) error {
 				return
 			}
 			fmt.Fprintf(cmd.OutOrStdout(), "%s version %s, git commit %s\n", callMeProgName, Version, GitCommit)
-			g, err := NewGithubRepo("ivanfetch/jkl")
+			if j.offline {
+				debugLog.Println("not checking for a newer version of jkl in offline mode")
+				return
+			}
+			g, err := NewGithubRepo("ivanfetch/jkl", WithHTTPClient(j.httpClient))
 			if err != nil {
 				// Since the current version was displayed, do not display an error if unable to contact Github.
 				debugLog.Printf("unable to determine if there is a newer version of jkl: %v\n", err)
 				return
 			}
-			latestTag, err := g.GetTagForLatestRelease()
+			latestTag, err := g.GetTagForLatestRelease(cmd.Context())
 			if err != nil {
 				debugLog.Printf("unable to determine if there is a newer version of jkl: %v\n", err)
 				return
@@ -91,6 +133,7 @@ func RunCLI(args []string, output, errOutput io.Writer) error {
 	versionCmd.MarkFlagsMutuallyExclusive("version-only", "commit-only")
 	rootCmd.AddCommand(versionCmd)
 
+	var repointShims bool
 	var installCmd = &cobra.Command{
 		Use:   "install <provider>:<source>[:version]",
 		Short: "Install a command-line tool",
@@ -99,11 +142,12 @@ func RunCLI(args []string, output, errOutput io.Writer) error {
 	If no version is specified, the latest version will be installed (not including pre-release versions). A partial major version will match the latest minor one.
 
 Available providers are:
-	github|gh - install a Github release. The source is specified as <Github user>/<Github repository>.
+	github|gh - install a Github release. The source is specified as <Github user>/<Github repository>, optionally preceded by a Github Enterprise Server hostname and a slash.
 	hashicorp|hashi - install a Hashicorp product. The source is the name of the Hashicorp product.`,
 		Example: `	jkl install github:fairwindsops/rbac-lookup
 	jkl install github:fairwindsops/rbac-lookup:0.9.0
 	jkl install github:fairwindsops/rbac-lookup:0.8
+	jkl install github:ghe.example.com/team/tool
 	jkl install hashicorp:terraform:1.2`,
 		Aliases: []string{"add", "inst", "i"},
 		Args: func(cmd *cobra.Command, args []string) error {
@@ -113,15 +157,32 @@ Available providers are:
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			_, err := j.Install(args[0])
+			if repointShims {
+				j.repointShims = true
+			}
+			_, err := j.Install(cmd.Context(), args[0])
 			if err != nil {
 				return err
 			}
 			return nil
 		},
 	}
+	installCmd.Flags().BoolVar(&repointShims, "repoint-shims", false, "Repoint the shim of the tool if it points to a different jkl binary, such as after jkl was moved (also enabled by repoint_shims in the configuration file).")
 	rootCmd.AddCommand(installCmd)
 
+	var reshimCmd = &cobra.Command{
+		Use:   "reshim",
+		Short: "Rebuild the shims of installed tools",
+		Long: fmt.Sprintf(`Create missing shims for installed tools in %s, repoint shims which point to a different jkl binary, and remove shims of tools which no longer have any versions installed.
+
+This repairs shims after the jkl binary was moved, such as by a package manager upgrade. Files in the shims directory which were not created by jkl are not changed.`, j.shimsDir),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return j.Reshim(cmd.Context(), cmd.OutOrStdout())
+		},
+	}
+	rootCmd.AddCommand(reshimCmd)
+
 	var uninstallCmd = &cobra.Command{
 		Use:   "uninstall <tool name>[:version]",
 		Short: "Uninstall a command-line tool",
@@ -139,7 +200,7 @@ Available providers are:
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			err := j.Uninstall(args[0])
+			err := j.Uninstall(cmd.Context(), args[0])
 			if err != nil {
 				return err
 			}
@@ -169,13 +230,255 @@ jkl list rbac-lookup`,
 	}
 	rootCmd.AddCommand(listCmd)
 
+	var unsetGlobal bool
+	var globalCmd = &cobra.Command{
+		Use:   "global <tool name> [<version>]",
+		Short: "Set the default version of a tool",
+		Long: fmt.Sprintf(`Set the default version of a tool, which is used when neither its JKL_<tool name> environment variable nor a .tool-versions file specifies a version.
+
+The version must be installed. A partial version matches the latest installed version beginning with it, and "latest" always uses the latest installed version. With only a tool name, the global version of that tool is shown.
+
+Global versions are stored in %s.`, j.globalVersionsFile),
+		Example: `	jkl global terraform 1.5
+	jkl global terraform latest
+	jkl global terraform
+	jkl global terraform --unset`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			toolName := args[0]
+			if unsetGlobal {
+				if len(args) == 2 {
+					return fmt.Errorf("a version cannot be specified when unsetting the global version of %s", toolName)
+				}
+				return j.UnsetGlobalVersion(cmd.Context(), toolName)
+			}
+			if len(args) == 1 {
+				globalVersion, ok, err := j.GlobalVersion(toolName)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					fmt.Fprintf(cmd.OutOrStdout(), "no global version is set for %s\n", toolName)
+					return nil
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), globalVersion)
+				return nil
+			}
+			matchedVersion, err := j.SetGlobalVersion(cmd.Context(), toolName, args[1])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "The global version of %s is now %s\n", toolName, matchedVersion)
+			return nil
+		},
+	}
+	globalCmd.Flags().BoolVar(&unsetGlobal, "unset", false, "Remove the global version of the tool.")
+	rootCmd.AddCommand(globalCmd)
+
+	var whichCmd = &cobra.Command{
+		Use:   "which <tool name>",
+		Short: "Show the path of the tool version that would be run",
+		Long:  "Show the path of the installed binary that the shim of a tool runs, for the current directory and environment. Use the current command to see why that version was selected.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			commandPath, err := j.ToolCommandPath(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), commandPath)
+			return nil
+		},
+	}
+	rootCmd.AddCommand(whichCmd)
+
+	var currentCmd = &cobra.Command{
+		Use:   "current [<tool name>]",
+		Short: "Show which versions of tools would be run, and why",
+		Long: `Show the version of each installed tool that its shim runs, for the current directory and environment, and where that version was specified.
+
+A version is selected by the first of:
+	The JKL_<tool name> environment variable.
+	A .tool-versions file in the current directory or its parents, as set by the local command.
+	The global version, as set by the global command.
+	The only installed version of the tool.`,
+		Example: `	jkl current
+	jkl current terraform`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 1 {
+				toolVersion, err := j.CurrentVersion(args[0])
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%s %s (set by %s)\n", toolVersion.Tool, toolVersion.Version, toolVersion.Source)
+				return nil
+			}
+			return j.displayCurrentVersions(cmd.OutOrStdout())
+		},
+	}
+	rootCmd.AddCommand(currentCmd)
+
+	var unsetLocal bool
+	var localCmd = &cobra.Command{
+		Use:   "local <tool name> <version> | <provider>:<source>[:version]",
+		Short: "Set the version of a tool for the current directory",
+		Long: fmt.Sprintf(`Set the version of a tool in the %[1]s file of the current directory, which is used when running the tool in this directory and its sub-directories, unless the JKL_<tool name> environment variable is set.
+
+A version that is specified with a tool name must be installed. A partial version matches the latest installed version beginning with it, and "latest" always uses the latest installed version. A tool-specification, as accepted by the install command, is installed if needed and its installed version is set.
+
+The %[1]s file is created if it does not exist. Comments and the order of other tools in the file are preserved.`, ASDFConfigFileName),
+		Example: `	jkl local terraform 1.5
+	jkl local hashicorp:terraform:1.5
+	jkl local terraform --unset`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			currentDir, err := os.Getwd()
+			if err != nil {
+				return err
+			}
+			if unsetLocal {
+				if len(args) == 2 {
+					return fmt.Errorf("a version cannot be specified when unsetting the local version of %s", args[0])
+				}
+				return WriteASDFToolVersion(filepath.Join(currentDir, ASDFConfigFileName), args[0], "")
+			}
+			toolName, version, err := j.resolveVersionArgs(cmd.Context(), args)
+			if err != nil {
+				return err
+			}
+			version, err = j.SetLocalVersion(currentDir, toolName, version)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "The version of %s in %s is now %s\n", toolName, filepath.Join(currentDir, ASDFConfigFileName), version)
+			return nil
+		},
+	}
+	localCmd.Flags().BoolVar(&unsetLocal, "unset", false, fmt.Sprintf("Remove the tool from the %s file of the current directory.", ASDFConfigFileName))
+	rootCmd.AddCommand(localCmd)
+
+	var useShell string
+	var useCmd = &cobra.Command{
+		Use:   "use <tool name> <version> | <provider>:<source>[:version]",
+		Short: "Set the version of a tool for the current shell session",
+		Long: `Output a shell command which sets the JKL_<tool name> environment variable, so the current shell session uses a version of a tool regardless of configuration files. Evaluate the output in your shell, as shown in the examples.
+
+Versions are matched like the local command, and a tool-specification is installed if needed.`,
+		Example: `	eval "$(jkl use terraform 1.5)"
+	eval "$(jkl use hashicorp:terraform:1.5)"
+	jkl use --shell fish terraform 1.5 | source`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return j.ShellUse(cmd.Context(), cmd.OutOrStdout(), useShell, args)
+		},
+	}
+	useCmd.Flags().StringVar(&useShell, "shell", os.Getenv("SHELL"), "The shell whose syntax is output: bash, zsh, or fish.")
+	rootCmd.AddCommand(useCmd)
+
+	var envShell string
+	var envCmd = &cobra.Command{
+		Use:   "env",
+		Short: "Output shell commands which set versions for the current directory",
+		Long: `Output shell commands which set the JKL_<tool name> environment variable of each installed tool, to its version from .tool-versions files or its global version, and add the jkl shims directory to PATH if needed. This lets your prompt and tools which do not run shims see the versions that shims would run.
+
+Variables set by previous output of this command are unset when a version is no longer specified. Other JKL_<tool name> environment variables, such as those set by the use command, are not changed.
+
+See the hook command to run this automatically in your shell.`,
+		Example: `	eval "$(jkl env)"
+	jkl env --shell fish | source`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return j.ShellEnv(cmd.OutOrStdout(), envShell)
+		},
+	}
+	envCmd.Flags().StringVar(&envShell, "shell", os.Getenv("SHELL"), "The shell whose syntax is output: bash, zsh, or fish.")
+	rootCmd.AddCommand(envCmd)
+
+	var hookShell string
+	var hookCmd = &cobra.Command{
+		Use:   "hook",
+		Short: "Output a shell snippet which sets versions as you change directories",
+		Long: `Output a snippet for your shell initialization file, which runs the env command before each prompt. The JKL_<tool name> environment variables then follow directory changes and edits to .tool-versions files.
+
+The bash, zsh, and fish shells are supported.`,
+		Example: `	# In ~/.bashrc
+	eval "$(jkl hook --shell bash)"
+	# In ~/.zshrc
+	eval "$(jkl hook --shell zsh)"
+	# In ~/.config/fish/config.fish
+	jkl hook --shell fish | source`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return j.ShellHook(cmd.OutOrStdout(), hookShell)
+		},
+	}
+	hookCmd.Flags().StringVar(&hookShell, "shell", os.Getenv("SHELL"), "The shell whose syntax is output: bash, zsh, or fish.")
+	rootCmd.AddCommand(hookCmd)
+
+	var execCmd = &cobra.Command{
+		Use:   "exec <tool name>[:version] | <provider>:<source>[:version] [--] [<argument>...]",
+		Short: "Run a specific version of a tool",
+		Long: `Run a specific version of a tool, regardless of the PATH environment variable, JKL_<tool name> environment variables, and .tool-versions files. Arguments following the tool are passed to it.
+
+With only a tool name, the latest installed version is run. A partial version matches the latest installed version beginning with it. A tool-specification, as accepted by the install command, is installed before it is run, which is useful for one-off runs of tools that are not yet installed.`,
+		Example: `	jkl exec terraform:0.11 -- init
+	jkl exec terraform:1.5 -- plan -out plan.tfplan
+	jkl exec hashicorp:terraform:1.5.7 -- version
+	jkl exec github:fairwindsops/rbac-lookup -- --help`,
+		Args:                  cobra.MinimumNArgs(1),
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			toolArgs := args[1:]
+			if len(toolArgs) > 0 && toolArgs[0] == "--" {
+				toolArgs = toolArgs[1:]
+			}
+			return j.ExecToolVersion(cmd.Context(), args[0], toolArgs, cmd.ErrOrStderr())
+		},
+	}
+	// Flags following the tool are passed to it.
+	execCmd.Flags().SetInterspersed(false)
+	rootCmd.AddCommand(execCmd)
+
+	var doctorJSON bool
+	var doctorCmd = &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the jkl environment for problems",
+		Long: `Check the jkl environment for problems, and show how to fix them. The checks are:
+	Whether the shims directory is in PATH, and whether other binaries earlier in PATH are run instead of shims.
+	Shims which are missing, point to a different jkl binary, were not created by jkl, or belong to tools with no versions installed.
+	Installed versions which are missing their binary.
+	Configuration and .tool-versions files which cannot be read.
+	Whether Github tokens are valid, and how much of the Github API rate limit remains.
+	Whether jkl directories are writable.
+	Temporary files left by interrupted jkl processes.
+
+The exit status is non-zero if any errors are found. Use --json for a machine-readable report.`,
+		Example: `	jkl doctor
+	jkl doctor --json`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true, // problems are described by the report
+		RunE: func(cmd *cobra.Command, args []string) error {
+			report := j.Doctor(cmd.Context())
+			err := displayDoctorReport(cmd.OutOrStdout(), report, doctorJSON)
+			if err != nil {
+				return err
+			}
+			if numErrors := report.Count(DoctorError); numErrors > 0 {
+				return fmt.Errorf("%s doctor found %d errors", callMeProgName, numErrors)
+			}
+			return nil
+		},
+	}
+	doctorCmd.Flags().BoolVar(&doctorJSON, "json", false, "Output the report as JSON.")
+	rootCmd.AddCommand(doctorCmd)
+
 	var updateSelfCmd = &cobra.Command{
 		Use:     "update",
 		Short:   "Update JKL to the latest release",
 		Long:    fmt.Sprintf("Update the JKL binary to the latest release. This will replace %s, retaining its current file mode.", j.executable),
 		Aliases: []string{"update-self", "update-jkl"},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			newVersion, isNewVersion, err := j.UpdateSelf()
+			newVersion, isNewVersion, err := j.UpdateSelf(cmd.Context())
 			if err != nil {
 				return err
 			}
@@ -189,13 +492,99 @@ jkl list rbac-lookup`,
 	}
 	rootCmd.AddCommand(updateSelfCmd)
 
-	cobra.CheckErr(rootCmd.Execute())
+	var cacheCmd = &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the cache of downloaded tools",
+		Long: fmt.Sprintf(`Manage the cache of downloaded tools.
+
+Downloaded release assets are cached in %s, and reused when a tool version is installed again.`, j.cacheDir),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+	rootCmd.AddCommand(cacheCmd)
+
+	var cacheListCmd = &cobra.Command{
+		Use:     "list",
+		Short:   "List cached downloads",
+		Long:    "List cached downloads, most recently used first.",
+		Aliases: []string{"ls", "l"},
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return j.displayDownloadCache(cmd.OutOrStdout())
+		},
+	}
+	cacheCmd.AddCommand(cacheListCmd)
+
+	var pruneMaxAge time.Duration
+	var pruneMaxSize string
+	var cachePruneCmd = &cobra.Command{
+		Use:   "prune",
+		Short: "Remove old cached downloads",
+		Long: `Remove cached downloads which have not been used recently, then remove the least recently used downloads until the cache is no larger than the maximum size.
+
+A maximum age or size of 0 is not enforced.`,
+		Example: `	jkl cache prune
+	jkl cache prune --max-age 168h --max-size 500MB`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			maxSize, err := parseByteSize(pruneMaxSize)
+			if err != nil {
+				return err
+			}
+			return j.pruneDownloadCache(cmd.OutOrStdout(), pruneMaxAge, maxSize)
+		},
+	}
+	cachePruneCmd.Flags().DurationVar(&pruneMaxAge, "max-age", 30*24*time.Hour, "Remove downloads which have not been used within this duration.")
+	cachePruneCmd.Flags().StringVar(&pruneMaxSize, "max-size", "2GB", "Remove the least recently used downloads until the cache is no larger than this size.")
+	cacheCmd.AddCommand(cachePruneCmd)
+
+	var cacheClearCmd = &cobra.Command{
+		Use:   "clear",
+		Short: "Remove all cached downloads and API responses",
+		Long:  "Remove all cached downloads, and API responses such as release listings which are used in offline mode.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := j.downloadCache().Clear()
+			if err != nil {
+				return fmt.Errorf("cannot clear the download cache: %v", err)
+			}
+			err = j.apiCache().Clear()
+			if err != nil {
+				return fmt.Errorf("cannot clear the API cache: %v", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Cleared the cache %s\n", j.cacheDir)
+			return nil
+		},
+	}
+	cacheCmd.AddCommand(cacheClearCmd)
+
+	err = rootCmd.ExecuteContext(ctx)
+	if err != nil && ctx.Err() != nil {
+		err = fmt.Errorf("interrupted: %v", err)
+	}
+	cobra.CheckErr(err)
 	return nil
 }
 
+// ToolCommandPath returns the path to the installed version of the tool that
+// its shim runs, as determined by configuration or the installed versions.
+func (j JKL) ToolCommandPath(toolName string) (string, error) {
+	return j.getManagedTool(toolName).commandPath()
+}
+
+// CurrentVersion returns the installed version of the tool that its shim
+// runs, and where that version was specified.
+func (j JKL) CurrentVersion(toolName string) (ToolVersion, error) {
+	return j.getManagedTool(toolName).currentVersion()
+}
+
 // RunShim executes the desired version of the tool which the JKL shim was called.
 // The remaining command-line arguments are passed to the actual tool being
 // executed.
+// If the desired version is not installed and automatic installation is
+// enabled, that version is installed first.
 func (j JKL) RunShim(args []string) error {
 	if os.Getenv("JKL_DEBUG") != "" {
 		EnableDebugOutput()
@@ -203,6 +592,14 @@ func (j JKL) RunShim(args []string) error {
 	calledProgName := filepath.Base(args[0])
 	tool := j.getManagedTool(calledProgName)
 	err := tool.Run(args[1:])
+	if errors.As(err, &versionNotInstalledError{}) {
+		// Progress is output to stderr, leaving stdout to the tool.
+		var commandPath string
+		commandPath, err = autoInstallForShim(calledProgName, os.Stderr)
+		if err == nil {
+			err = ExecCommand(append([]string{commandPath}, args[1:]...))
+		}
+	}
 	if err != nil {
 		return fmt.Errorf("jkl: %v", err)
 	}
@@ -3,6 +3,7 @@ package jkl
 import (
 	"os"
 	"os/exec"
+	"path/filepath"
 	"syscall"
 )
 
@@ -26,20 +27,79 @@ func ExecCommand(commandAndArgs []string) error {
 // returning the path to the command, otherwise
 // returning an empty string if the command is not found in the PATH
 // environment variable.
-// This is used to find user-installed binaries. JKL-installed binaries are
-// instead located within the JKL `installs` directory, using the function
-// getInstalledCommandPath().
-func FindCommandVersion(command, version string) (string, error) {
-	commandWithVersion := command + "." + version
-	found, err := exec.LookPath(commandWithVersion)
-	execErr, ok := err.(*exec.Error)
-	if ok && execErr.Err == exec.ErrNotFound {
+// This is used to find user-installed binaries, which are named like
+// command.x.y.z or command-x.y.z, with or without a leading v in the version.
+// Directories such as the JKL shims directory can be excluded from the search.
+// JKL-installed binaries are instead located within the JKL `installs`
+// directory, using the function getInstalledCommandPath().
+func FindCommandVersion(command, version string, excludeDirs ...string) (string, error) {
+	var names []string
+	for _, v := range []string{version, toggleVPrefix(version)} {
+		names = append(names, command+"."+v, command+"-"+v)
+	}
+	found, err := findCommandInPath(names, excludeDirs)
+	if err != nil {
+		return "", err
+	}
+	if found == "" {
 		debugLog.Printf("did not find command %s version %s\n", command, version)
 		return "", nil
 	}
+	debugLog.Printf("found %q for command %s version %s\n", found, command, version)
+	return found, nil
+}
+
+// FindSystemCommand returns the path to the specified command in the PATH
+// environment variable, otherwise returning an empty string if the command is
+// not found. Excluding the JKL shims directory finds a command that is not
+// managed by JKL, such as one installed by the operating system.
+func FindSystemCommand(command string, excludeDirs ...string) (string, error) {
+	found, err := findCommandInPath([]string{command}, excludeDirs)
 	if err != nil {
 		return "", err
 	}
-	debugLog.Printf("found %q for command %s version %s\n", found, command, version)
+	debugLog.Printf("found %q in PATH for command %s\n", found, command)
 	return found, nil
 }
+
+// findCommandInPath returns the path to the first executable file with one
+// of the specified names, in the directories of the PATH environment variable
+// other than excludeDirs. All names are tried in a directory before moving on
+// to the next directory.
+func findCommandInPath(names, excludeDirs []string) (string, error) {
+	excludeStats := make([]os.FileInfo, 0, len(excludeDirs))
+	for _, dir := range excludeDirs {
+		stat, err := os.Stat(dir)
+		if err != nil {
+			continue // a missing directory cannot be in the PATH
+		}
+		excludeStats = append(excludeStats, stat)
+	}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" {
+			continue // the current directory is not searched
+		}
+		dirStat, err := os.Stat(dir)
+		if err != nil {
+			continue
+		}
+		var excluded bool
+		for _, excludeStat := range excludeStats {
+			if os.SameFile(dirStat, excludeStat) {
+				excluded = true
+				break
+			}
+		}
+		if excluded {
+			debugLog.Printf("not searching directory %s for %v", dir, names)
+			continue
+		}
+		for _, name := range names {
+			found, err := exec.LookPath(filepath.Join(dir, name))
+			if err == nil {
+				return filepath.Abs(found)
+			}
+		}
+	}
+	return "", nil
+}
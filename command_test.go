@@ -54,24 +54,75 @@ func TestRunCommandToTouchAFile(t *testing.T) {
 }
 
 func TestFindCommandVersion(t *testing.T) {
-	tempDir := t.TempDir()
-	t.Setenv("PATH", tempDir)
-	want := tempDir + "/testcommand.1.0"
-	f, err := os.Create(want)
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer f.Close()
-	err = f.Chmod(0755)
-	if err != nil {
-		t.Fatal(err)
-	}
-	got, err := jkl.FindCommandVersion("testcommand", "1.0")
-	if err != nil {
-		t.Fatal(err)
+	testCases := []struct {
+		description string
+		fileName    string // created in a directory in PATH
+		excluded    bool   // the directory is excluded from the search
+		version     string
+		wantFound   bool
+	}{
+		{
+			description: "dot separated version",
+			fileName:    "testcommand.1.0",
+			version:     "1.0",
+			wantFound:   true,
+		},
+		{
+			description: "dash separated version",
+			fileName:    "testcommand-1.0",
+			version:     "1.0",
+			wantFound:   true,
+		},
+		{
+			description: "version with a leading v",
+			fileName:    "testcommand-v1.0",
+			version:     "1.0",
+			wantFound:   true,
+		},
+		{
+			description: "version without a leading v",
+			fileName:    "testcommand.1.0",
+			version:     "v1.0",
+			wantFound:   true,
+		},
+		{
+			description: "different version",
+			fileName:    "testcommand.1.0.1",
+			version:     "1.0",
+		},
+		{
+			description: "excluded directory",
+			fileName:    "testcommand.1.0",
+			excluded:    true,
+			version:     "1.0",
+		},
 	}
-	if got != want {
-		t.Fatalf("want %q, got %q", want, got)
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			tempDir := t.TempDir()
+			t.Setenv("PATH", tempDir)
+			filePath := tempDir + "/" + tc.fileName
+			err := os.WriteFile(filePath, []byte("#!/bin/sh\n"), 0755)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var excludeDirs []string
+			if tc.excluded {
+				excludeDirs = append(excludeDirs, tempDir)
+			}
+			got, err := jkl.FindCommandVersion("testcommand", tc.version, excludeDirs...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want string
+			if tc.wantFound {
+				want = filePath
+			}
+			if got != want {
+				t.Fatalf("want %q, got %q", want, got)
+			}
+		})
 	}
 }
 
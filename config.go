@@ -0,0 +1,132 @@
+package jkl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultConfigFile is the jkl configuration file, which can be overridden
+// by the JKL_CONFIG environment variable.
+const DefaultConfigFile = "~/.jkl/config.yaml"
+
+// Config holds settings from the jkl configuration file.
+type Config struct {
+	// GithubHosts configures Github hosts, such as Github Enterprise
+	// Server, by the hostname used in tool specifications.
+	GithubHosts map[string]GithubHostConfig `yaml:"github_hosts"`
+	// HTTP configures how jkl connects to APIs and downloads tools.
+	HTTP HTTPConfig `yaml:"http"`
+	// AutoInstall installs the version of a tool that its shim would run, if
+	// that version is not installed. This is also enabled by setting the
+	// JKL_AUTO_INSTALL environment variable to any value.
+	AutoInstall bool `yaml:"auto_install"`
+	// RepointShims repoints the shim of a tool being installed, when the shim
+	// points to a different jkl binary, such as after jkl was moved.
+	RepointShims bool `yaml:"repoint_shims"`
+	// Mirrors rewrite URLs, for example to download releases from an
+	// internal Artifactory or Nexus repository.
+	Mirrors []MirrorConfig `yaml:"mirrors"`
+	// Extract limits what is extracted from downloaded archives.
+	Extract ExtractConfig `yaml:"extract"`
+}
+
+// ExtractConfig overrides the limits used when extracting downloaded
+// archives, which protect against archives that would exhaust disk space.
+type ExtractConfig struct {
+	// MaxBytes limits the total uncompressed size of extracted files, and
+	// defaults to DefaultMaxExtractedBytes.
+	MaxBytes int64 `yaml:"max_bytes"`
+	// MaxFiles limits the number of extracted files, and defaults to
+	// DefaultMaxExtractedFiles.
+	MaxFiles int `yaml:"max_files"`
+}
+
+// extractOptions returns the ExtractOptions which apply the configured
+// limits.
+func (c ExtractConfig) extractOptions() ([]ExtractOption, error) {
+	if c.MaxBytes < 0 || c.MaxFiles < 0 {
+		return nil, errors.New("the extract max_bytes and max_files limits cannot be negative")
+	}
+	var options []ExtractOption
+	if c.MaxBytes > 0 {
+		options = append(options, WithMaxExtractedBytes(c.MaxBytes))
+	}
+	if c.MaxFiles > 0 {
+		options = append(options, WithMaxExtractedFiles(c.MaxFiles))
+	}
+	return options, nil
+}
+
+// HTTPConfig configures the HTTP client.
+type HTTPConfig struct {
+	// Proxy is the URL of an HTTP proxy, which is used instead of the
+	// HTTPS_PROXY and HTTP_PROXY environment variables.
+	Proxy string `yaml:"proxy"`
+	// NoProxy lists domains which are not accessed using Proxy.
+	NoProxy []string `yaml:"no_proxy"`
+	// CACertificates lists PEM files of CA certificates to trust in addition
+	// to the system ones, such as for a TLS-intercepting proxy.
+	CACertificates []string `yaml:"ca_certificates"`
+	// Timeout limits how long a request and its response can take, and
+	// defaults to DefaultHTTPTimeout.
+	Timeout time.Duration `yaml:"timeout"`
+	// HostTimeouts overrides Timeout for specific hostnames.
+	HostTimeouts map[string]time.Duration `yaml:"host_timeouts"`
+}
+
+// MirrorConfig rewrites URLs beginning with From, to begin with To.
+type MirrorConfig struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+// GithubHostConfig configures access to a Github host.
+type GithubHostConfig struct {
+	// APIURL is the base URL of the Github API, which defaults to
+	// https://<host>/api/v3 for Github Enterprise Server.
+	APIURL string `yaml:"api_url"`
+	// Token is used instead of discovering a token from the environment.
+	Token string `yaml:"token"`
+}
+
+// LoadConfig reads the specified jkl configuration file. A file that does not
+// exist results in an empty configuration.
+func LoadConfig(filePath string) (Config, error) {
+	debugLog.Printf("reading configuration file %s", filePath)
+	b, err := os.ReadFile(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		debugLog.Printf("the configuration file %s does not exist", filePath)
+		return Config{}, nil
+	}
+	if err != nil {
+		return Config{}, err
+	}
+	var c Config
+	decoder := yaml.NewDecoder(bytes.NewReader(b))
+	decoder.KnownFields(true)
+	err = decoder.Decode(&c)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return Config{}, fmt.Errorf("invalid configuration file %s: %v", filePath, err)
+	}
+	// Hostnames are matched case-insensitively.
+	githubHosts := make(map[string]GithubHostConfig, len(c.GithubHosts))
+	for host, hostConfig := range c.GithubHosts {
+		githubHosts[strings.ToLower(host)] = hostConfig
+	}
+	c.GithubHosts = githubHosts
+	return c, nil
+}
+
+// githubHost returns the configuration for the specified Github host.
+func (c Config) githubHost(host string) (hostConfig GithubHostConfig, found bool) {
+	hostConfig, found = c.GithubHosts[strings.ToLower(host)]
+	return hostConfig, found
+}
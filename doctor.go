@@ -0,0 +1,430 @@
+package jkl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Statuses of a DoctorCheck.
+const (
+	DoctorOK      = "ok"
+	DoctorWarning = "warning"
+	DoctorError   = "error"
+)
+
+// doctorLeftoverAge is how old temporary files must be for Doctor to report
+// them as left over, so those of jkl processes which are still running are
+// not reported.
+const doctorLeftoverAge = time.Hour
+
+// doctorMinRateLimitRemaining is the fraction of the Github API rate limit
+// below which Doctor warns that little remains.
+const doctorMinRateLimitRemaining = 0.1
+
+// DoctorCheck is the result of checking one aspect of the jkl environment.
+type DoctorCheck struct {
+	Check   string `json:"check"`         // the area checked, such as path or shims
+	Status  string `json:"status"`        // DoctorOK, DoctorWarning, or DoctorError
+	Message string `json:"message"`       // what was found
+	Fix     string `json:"fix,omitempty"` // how to fix a problem
+}
+
+// DoctorReport holds the results of Doctor.
+type DoctorReport struct {
+	Checks []DoctorCheck `json:"checks"`
+}
+
+// Count returns the number of checks with the specified status.
+func (r DoctorReport) Count(status string) int {
+	var n int
+	for _, check := range r.Checks {
+		if check.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// add appends a check to the report.
+func (r *DoctorReport) add(check, status, fix, format string, a ...interface{}) {
+	r.Checks = append(r.Checks, DoctorCheck{
+		Check:   check,
+		Status:  status,
+		Message: fmt.Sprintf(format, a...),
+		Fix:     fix,
+	})
+}
+
+// Doctor checks the jkl environment for problems, such as shims which
+// are not run because of the PATH environment variable, broken shims or
+// installations, unreadable configuration files, Github token problems,
+// directories that are not writable, and temporary files left over by
+// interrupted jkl processes. Each problem includes how to fix it.
+// Github API requests are skipped in offline mode.
+func (j JKL) Doctor(ctx context.Context) DoctorReport {
+	var report DoctorReport
+	toolNames, err := j.listInstalledTools()
+	if err != nil {
+		report.add("installs", DoctorError, "", "cannot list installed tools in %s: %v", j.installsDir, err)
+	}
+	j.checkPath(&report, toolNames)
+	j.checkShims(&report, toolNames)
+	j.checkInstalls(&report, toolNames)
+	j.checkConfigFiles(&report)
+	j.checkGithubTokens(ctx, &report)
+	j.checkDirectories(&report)
+	j.checkLeftovers(&report)
+	return report
+}
+
+// checkPath checks that the shims directory is in PATH, and that installed
+// tools are not shadowed by binaries earlier in PATH.
+func (j JKL) checkPath(report *DoctorReport, toolNames []string) {
+	shimsDirInPath, err := directoryInPath(j.shimsDir)
+	if err != nil {
+		report.add("path", DoctorError, "", "cannot verify whether the shims directory %s is in PATH: %v", j.shimsDir, err)
+		return
+	}
+	if !shimsDirInPath {
+		report.add("path", DoctorError, fmt.Sprintf(`add the shims directory to the beginning of PATH in your shell initialization file, such as: export PATH=%s:"$PATH"`, shellQuote(j.shimsDir)), "the shims directory %s is not in PATH, so tools are not run by their shims", j.shimsDir)
+		return
+	}
+	report.add("path", DoctorOK, "", "the shims directory %s is in PATH", j.shimsDir)
+	for _, toolName := range toolNames {
+		found, err := findCommandInPath([]string{toolName}, nil)
+		if err != nil || found == "" || sameDirectory(filepath.Dir(found), j.shimsDir) {
+			continue
+		}
+		report.add("path", DoctorWarning, fmt.Sprintf("move %s before %s in PATH, or remove %s", j.shimsDir, filepath.Dir(found), found), "%s runs %s instead of its shim, because %s is earlier in PATH", toolName, found, filepath.Dir(found))
+	}
+}
+
+// sameDirectory returns true if both paths are the same existing directory.
+func sameDirectory(a, b string) bool {
+	aStat, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bStat, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(aStat, bStat)
+}
+
+// checkShims checks that each installed tool has a shim pointing to the jkl
+// binary, and that the shims directory has no dangling, orphaned, or foreign
+// shims.
+func (j JKL) checkShims(report *DoctorReport, toolNames []string) {
+	reshimFix := fmt.Sprintf("run: %s reshim", callMeProgName)
+	var problems int
+	for _, toolName := range toolNames {
+		shimPath := filepath.Join(j.shimsDir, toolName)
+		state, target, err := j.inspectShim(shimPath)
+		switch {
+		case err != nil:
+			report.add("shims", DoctorError, "", "cannot inspect the shim %s: %v", shimPath, err)
+		case state == shimMissing:
+			report.add("shims", DoctorError, reshimFix, "the shim %s is missing, so %s is not run by jkl", shimPath, toolName)
+		case state == shimStale:
+			report.add("shims", DoctorError, reshimFix, "the shim %s points to %q instead of %q", shimPath, target, j.GetExecutable())
+		case state == shimForeign:
+			report.add("shims", DoctorError, fmt.Sprintf("move %s out of the shims directory, then run: %s reshim", shimPath, callMeProgName), "%s was not created by jkl, so %s is not run by jkl", shimPath, toolName)
+		default:
+			continue
+		}
+		problems++
+	}
+	entries, err := os.ReadDir(j.shimsDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		report.add("shims", DoctorError, "", "cannot list the shims directory %s: %v", j.shimsDir, err)
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == callMeProgName || strings.HasPrefix(name, ".") || slices.Contains(toolNames, name) {
+			continue
+		}
+		shimPath := filepath.Join(j.shimsDir, name)
+		state, target, err := j.inspectShim(shimPath)
+		switch {
+		case err != nil:
+			report.add("shims", DoctorError, "", "cannot inspect the shim %s: %v", shimPath, err)
+		case state == shimCurrent || state == shimStale:
+			report.add("shims", DoctorWarning, reshimFix, "the shim %s is orphaned, as no versions of %s are installed", shimPath, name)
+		case target != "":
+			report.add("shims", DoctorWarning, fmt.Sprintf("move %s out of the shims directory", shimPath), "%s is a symlink to %q which was not created by jkl", shimPath, target)
+		default:
+			report.add("shims", DoctorWarning, fmt.Sprintf("move %s out of the shims directory", shimPath), "%s is a file which was not created by jkl", shimPath)
+		}
+		problems++
+	}
+	if problems == 0 {
+		report.add("shims", DoctorOK, "", "the shims of %d installed tools are correct", len(toolNames))
+	}
+}
+
+// checkInstalls checks that each installed version of a tool contains its
+// binary.
+func (j JKL) checkInstalls(report *DoctorReport, toolNames []string) {
+	var problems, numVersions int
+	for _, toolName := range toolNames {
+		tool := j.getManagedTool(toolName)
+		versions, _, err := tool.listInstalledVersions()
+		if err != nil {
+			report.add("installs", DoctorError, "", "cannot list installed versions of %s: %v", toolName, err)
+			problems++
+			continue
+		}
+		for _, version := range versions {
+			numVersions++
+			_, found, err := tool.path(version)
+			if err != nil {
+				report.add("installs", DoctorError, "", "cannot find the binary of %s %s: %v", toolName, version, err)
+				problems++
+				continue
+			}
+			if found {
+				continue
+			}
+			fix := fmt.Sprintf("run: %s uninstall %s:%s", callMeProgName, toolName, version)
+			if providerAndSource, ok, _ := tool.source(); ok {
+				fix += fmt.Sprintf(" && %s install %s:%s", callMeProgName, providerAndSource, version)
+			}
+			report.add("installs", DoctorError, fix, "%s %s is missing its binary %s", toolName, version, filepath.Join(j.installsDir, toolName, version, toolName))
+			problems++
+		}
+	}
+	if problems == 0 {
+		report.add("installs", DoctorOK, "", "%d installed versions of %d tools have their binaries", numVersions, len(toolNames))
+	}
+}
+
+// checkConfigFiles checks that the jkl configuration file, the global
+// versions file, and .tool-versions files in the current directory and its
+// parents can be read.
+func (j JKL) checkConfigFiles(report *DoctorReport) {
+	var problems int
+	if j.configErr != nil {
+		report.add("config", DoctorError, fmt.Sprintf("correct or remove %s", j.configFile), "cannot load the configuration file: %v", j.configErr)
+		problems++
+	}
+	asdfFiles := []string{j.globalVersionsFile}
+	currentDir, err := os.Getwd()
+	if err == nil {
+		var locations []string
+		locations, err = listPathsByParent(ASDFConfigFileName, currentDir, "/")
+		for _, location := range locations {
+			asdfFiles = append(asdfFiles, filepath.Join(location, ASDFConfigFileName))
+		}
+	}
+	if err != nil {
+		report.add("config", DoctorError, "", "cannot find %s files: %v", ASDFConfigFileName, err)
+		problems++
+	}
+	for _, filePath := range asdfFiles {
+		_, err := parseASDFConfigFile(filePath)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			report.add("config", DoctorError, fmt.Sprintf("correct the permissions of %s", filePath), "cannot read %s: %v", filePath, err)
+			problems++
+		}
+	}
+	if problems == 0 {
+		report.add("config", DoctorOK, "", "configuration files are readable")
+	}
+}
+
+// githubRateLimit is the response of the Github rate_limit API.
+type githubRateLimit struct {
+	Resources struct {
+		Core struct {
+			Limit     int   `json:"limit"`
+			Remaining int   `json:"remaining"`
+			Reset     int64 `json:"reset"`
+		} `json:"core"`
+	} `json:"resources"`
+}
+
+// checkGithubTokens checks that Github tokens for the public Github API and
+// configured Github hosts are valid, and that their rate limit has headroom.
+func (j JKL) checkGithubTokens(ctx context.Context, report *DoctorReport) {
+	if j.offline {
+		report.add("github", DoctorOK, "", "skipped checking Github tokens in offline mode")
+		return
+	}
+	sources := []string{"owner/repo"}
+	hosts := make([]string, 0, len(j.config.GithubHosts))
+	for host := range j.config.GithubHosts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		sources = append(sources, host+"/owner/repo")
+	}
+	for _, source := range sources {
+		c, err := NewGithubClient(j.githubOptions(source)...)
+		if err != nil {
+			report.add("github", DoctorError, "", "cannot configure a Github client for %s: %v", source, err)
+			continue
+		}
+		j.checkGithubToken(ctx, report, c)
+	}
+}
+
+// checkGithubToken checks the token and rate limit of the Github client.
+func (j JKL) checkGithubToken(ctx context.Context, report *DoctorReport, c *GithubClient) {
+	tokenFix := "set the GH_TOKEN environment variable to a valid Github personal access token, or log in using: gh auth login"
+	if !isPublicGithubAPI(c.apiHost) {
+		tokenFix = "set the GH_ENTERPRISE_TOKEN environment variable to a valid personal access token for this host, or configure its token in the github_hosts section of the configuration file"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.apiHost+"/rate_limit", nil)
+	if err != nil {
+		report.add("github", DoctorError, "", "cannot create a request for %s: %v", c.apiHost, err)
+		return
+	}
+	if c.token != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("token %s", c.token))
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		report.add("github", DoctorWarning, "check your network connection and the http settings of the configuration file", "cannot reach the Github API %s: %v", c.apiHost, err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized {
+		tokenSource := "the configuration file"
+		if !c.tokenIsSet {
+			_, tokenSource = discoverGithubToken(c.apiHost)
+		}
+		report.add("github", DoctorError, tokenFix, "the Github token for %s from %s is invalid or expired", c.apiHost, tokenSource)
+		return
+	}
+	if resp.StatusCode == http.StatusNotFound && !isPublicGithubAPI(c.apiHost) {
+		report.add("github", DoctorOK, "", "rate limiting is disabled for the Github API %s", c.apiHost)
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		report.add("github", DoctorWarning, "", "unexpected HTTP %d checking the rate limit of the Github API %s", resp.StatusCode, c.apiHost)
+		return
+	}
+	var rateLimit githubRateLimit
+	err = json.NewDecoder(resp.Body).Decode(&rateLimit)
+	if err != nil {
+		report.add("github", DoctorWarning, "", "cannot decode the rate limit of the Github API %s: %v", c.apiHost, err)
+		return
+	}
+	core := rateLimit.Resources.Core
+	authPhrase := "your token"
+	if c.token == "" {
+		authPhrase = "unauthenticated requests"
+		report.add("github", DoctorWarning, tokenFix, "no Github token was found for %s, unauthenticated requests have a lower rate limit", c.apiHost)
+	}
+	if core.Limit > 0 && float64(core.Remaining) < float64(core.Limit)*doctorMinRateLimitRemaining {
+		resetPhrase := ""
+		if core.Reset > 0 {
+			resetPhrase = fmt.Sprintf(", and resets at %s", time.Unix(core.Reset, 0).Format(time.Kitchen))
+		}
+		report.add("github", DoctorWarning, "wait for the rate limit to reset, or avoid API requests using --offline", "only %d of %d Github API requests remain for %s at %s%s", core.Remaining, core.Limit, authPhrase, c.apiHost, resetPhrase)
+		return
+	}
+	report.add("github", DoctorOK, "", "%d of %d Github API requests remain for %s at %s", core.Remaining, core.Limit, authPhrase, c.apiHost)
+}
+
+// checkDirectories checks that jkl can write to its directories. A directory
+// which does not exist is created when needed, so its nearest existing parent
+// is checked instead.
+func (j JKL) checkDirectories(report *DoctorReport) {
+	dirs := []string{j.installsDir, j.shimsDir, j.locksDir, j.cacheDir, filepath.Dir(j.globalVersionsFile)}
+	var problems int
+	for _, dir := range dirs {
+		if dir == "" || dir == "." {
+			continue
+		}
+		existingDir := dir
+		for {
+			_, err := os.Stat(existingDir)
+			if err == nil || !errors.Is(err, fs.ErrNotExist) || filepath.Dir(existingDir) == existingDir {
+				break
+			}
+			existingDir = filepath.Dir(existingDir)
+		}
+		f, err := os.CreateTemp(existingDir, "."+callMeProgName+"-doctor-")
+		if err != nil {
+			report.add("directories", DoctorError, fmt.Sprintf("make %s writable by your user", existingDir), "cannot write to %s: %v", dir, err)
+			problems++
+			continue
+		}
+		f.Close()
+		os.Remove(f.Name())
+	}
+	if problems == 0 {
+		report.add("directories", DoctorOK, "", "jkl directories are writable")
+	}
+}
+
+// checkLeftovers checks for temporary files left by interrupted jkl
+// processes: work directories in the system temporary directory, and staged
+// or backed up versions in the installs directory.
+func (j JKL) checkLeftovers(report *DoctorReport) {
+	patterns := []string{
+		filepath.Join(os.TempDir(), callMeProgName+"-*"),
+		filepath.Join(j.installsDir, "*", ".*.staging-*"),
+		filepath.Join(j.shimsDir, ".*.new"),
+	}
+	var leftovers []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			report.add("leftovers", DoctorError, "", "cannot look for leftover files matching %s: %v", pattern, err)
+			continue
+		}
+		for _, match := range matches {
+			stat, err := os.Lstat(match)
+			if err != nil || time.Since(stat.ModTime()) < doctorLeftoverAge {
+				continue
+			}
+			leftovers = append(leftovers, match)
+		}
+	}
+	if len(leftovers) == 0 {
+		report.add("leftovers", DoctorOK, "", "no temporary files were left by interrupted jkl processes")
+		return
+	}
+	quotedLeftovers := make([]string, len(leftovers))
+	for i, leftover := range leftovers {
+		quotedLeftovers[i] = shellQuote(leftover)
+	}
+	report.add("leftovers", DoctorWarning, "run: rm -rf "+strings.Join(quotedLeftovers, " "), "temporary files were left by interrupted jkl processes: %s", strings.Join(leftovers, ", "))
+}
+
+// displayDoctorReport writes the report, either as JSON, or as a line per
+// check followed by the fix for any problem.
+func displayDoctorReport(output io.Writer, report DoctorReport, asJSON bool) error {
+	if asJSON {
+		encoder := json.NewEncoder(output)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	for _, check := range report.Checks {
+		fmt.Fprintf(output, "[%s] %s: %s\n", check.Status, check.Check, check.Message)
+		if check.Fix != "" {
+			fmt.Fprintf(output, "\tTo fix: %s\n", check.Fix)
+		}
+	}
+	fmt.Fprintf(output, "Found %d errors and %d warnings\n", report.Count(DoctorError), report.Count(DoctorWarning))
+	return nil
+}
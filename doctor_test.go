@@ -0,0 +1,143 @@
+package jkl_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ivanfetch/jkl"
+)
+
+func TestDoctor(t *testing.T) {
+	// Not parallel, as the environment is changed.
+	tempDir := t.TempDir()
+	// Other temporary files would be reported as leftovers.
+	t.Setenv("TMPDIR", filepath.Join(tempDir, "tmp"))
+	err := os.Mkdir(filepath.Join(tempDir, "tmp"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newFakeGithubServer(t, "jkltest/tool", "v1.0.0", "v1.1.0")
+	j := newTestJKL(t, tempDir, server, jkl.WithGithubClientOptions(jkl.WithToken("valid-token")))
+	for _, spec := range []string{"github:jkltest/tool:1.0.0", "github:jkltest/tool:1.1.0"} {
+		_, err := j.Install(context.Background(), spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	shimsDir := filepath.Join(tempDir, "bin")
+	// Another tool binary earlier in PATH than the shims directory.
+	otherDir := filepath.Join(tempDir, "other")
+	err = os.Mkdir(otherDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(otherDir, "tool"), []byte("#!/bin/sh\n"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", otherDir+string(os.PathListSeparator)+shimsDir)
+	// A version which is missing its binary.
+	err = os.Remove(filepath.Join(tempDir, "installs/tool/v1.1.0/tool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A shim of a tool with no versions installed.
+	err = os.Symlink(filepath.Join(tempDir, "old/jkl"), filepath.Join(shimsDir, "uninstalled"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A global versions file which cannot be read.
+	err = os.Mkdir(filepath.Join(tempDir, "tool-versions"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A staged installation left by an interrupted jkl process.
+	stageDir := filepath.Join(tempDir, "installs/tool/.v1.2.0.staging-123")
+	err = os.Mkdir(stageDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stageTime := time.Now().Add(-2 * time.Hour)
+	err = os.Chtimes(stageDir, stageTime, stageTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := j.Doctor(context.Background())
+	wantChecks := []jkl.DoctorCheck{
+		{Check: "path", Status: jkl.DoctorWarning, Message: "tool runs " + filepath.Join(otherDir, "tool") + " instead of its shim", Fix: "move " + shimsDir + " before " + otherDir},
+		{Check: "shims", Status: jkl.DoctorWarning, Message: "is orphaned, as no versions of uninstalled are installed", Fix: "jkl reshim"},
+		{Check: "installs", Status: jkl.DoctorError, Message: "tool v1.1.0 is missing its binary", Fix: "jkl uninstall tool:v1.1.0 && jkl install github:jkltest/tool:v1.1.0"},
+		{Check: "config", Status: jkl.DoctorError, Message: "cannot read " + filepath.Join(tempDir, "tool-versions")},
+		{Check: "github", Status: jkl.DoctorOK, Message: "4000 of 5000 Github API requests remain for your token"},
+		{Check: "directories", Status: jkl.DoctorOK, Message: "writable"},
+		{Check: "leftovers", Status: jkl.DoctorWarning, Message: stageDir, Fix: "rm -rf " + stageDir},
+	}
+	for _, want := range wantChecks {
+		var found bool
+		for _, got := range report.Checks {
+			if got.Check == want.Check && got.Status == want.Status && strings.Contains(got.Message, want.Message) && strings.Contains(got.Fix, want.Fix) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("want a %s %s check with message containing %q and fix containing %q, got checks: %+v", want.Status, want.Check, want.Message, want.Fix, report.Checks)
+		}
+	}
+	if got := report.Count(jkl.DoctorError); got != 2 {
+		t.Errorf("want 2 errors, got %d: %+v", got, report.Checks)
+	}
+}
+
+func TestDoctorGithubToken(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		description string
+		token       string
+		wantStatus  map[string]bool // statuses of github checks
+		wantMessage string
+	}{
+		{
+			description: "valid token",
+			token:       "valid-token",
+			wantStatus:  map[string]bool{jkl.DoctorOK: true},
+			wantMessage: "4000 of 5000 Github API requests remain for your token",
+		},
+		{
+			description: "invalid token",
+			token:       "expired-token",
+			wantStatus:  map[string]bool{jkl.DoctorError: true},
+			wantMessage: "is invalid or expired",
+		},
+		{
+			description: "no token and little rate limit remaining",
+			wantStatus:  map[string]bool{jkl.DoctorWarning: true},
+			wantMessage: "only 3 of 5000 Github API requests remain for unauthenticated requests",
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			server := newFakeGithubServer(t, "jkltest/tool", "v1.0.0")
+			j := newTestJKL(t, t.TempDir(), server, jkl.WithGithubClientOptions(jkl.WithToken(tc.token)))
+			var messages []string
+			for _, check := range j.Doctor(context.Background()).Checks {
+				if check.Check != "github" {
+					continue
+				}
+				if !tc.wantStatus[check.Status] {
+					t.Errorf("unexpected %s check: %s", check.Status, check.Message)
+				}
+				messages = append(messages, check.Message)
+			}
+			if !strings.Contains(strings.Join(messages, "\n"), tc.wantMessage) {
+				t.Fatalf("want a github check with message containing %q, got %q", tc.wantMessage, messages)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package jkl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultMaxResumes is how many times an interrupted download is resumed.
+const defaultMaxResumes = 3
+
+// downloader saves the response body of HTTP requests as files.
+type downloader struct {
+	do         requestDoer
+	cache      *DownloadCache // optional, reuses previous downloads
+	offline    bool           // only use cached downloads
+	progress   ProgressFunc   // optional, reports download progress
+	maxResumes int
+}
+
+// download saves the response body of the HTTP request as fileName,
+// returning the path to the downloaded file.
+// If a DownloadCache is specified, a previously cached download of the same
+// URL is returned instead of sending the request, and new downloads are
+// stored in the cache. Otherwise the file is saved in a created temporary
+// directory.
+// In offline mode, only a cached download is returned.
+func (d downloader) download(req *http.Request, fileName string) (filePath string, err error) {
+	URL := req.URL.String()
+	if d.cache != nil {
+		download, found, err := d.cache.Get(URL)
+		if err != nil {
+			return "", err
+		}
+		if found {
+			return download.Path(), nil
+		}
+	}
+	if d.offline {
+		return "", fmt.Errorf("%s is not in the download cache, and cannot be downloaded in offline mode", URL)
+	}
+	var f *os.File
+	if d.cache != nil {
+		f, err = d.cache.createTemp(URL, fileName)
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(f.Name()) // fails harmlessly once stored in the cache
+	} else {
+		tempDir, err := os.MkdirTemp(os.TempDir(), callMeProgName+"-")
+		if err != nil {
+			return "", err
+		}
+		defer func() {
+			if filePath == "" { // the download failed
+				os.RemoveAll(tempDir)
+			}
+		}()
+		f, err = os.Create(filepath.Join(tempDir, fileName))
+		if err != nil {
+			return "", err
+		}
+	}
+	defer f.Close()
+	err = d.copyWithResume(req, fileName, f)
+	if err != nil {
+		return "", err
+	}
+	err = f.Sync()
+	if err != nil {
+		return "", err
+	}
+	err = f.Close()
+	if err != nil {
+		return "", err
+	}
+	if d.cache != nil {
+		download, err := d.cache.putFile(URL, fileName, f.Name())
+		if err != nil {
+			return "", fmt.Errorf("while downloading %s: %v", URL, err)
+		}
+		debugLog.Printf("downloaded %s to %s", URL, download.Path())
+		return download.Path(), nil
+	}
+	debugLog.Printf("downloaded %s to %s", URL, f.Name())
+	return f.Name(), nil
+}
+
+// copyWithResume writes the response body of the HTTP request to the file.
+// If the response is interrupted and the server supports range requests,
+// the request is resent for the remainder of the body.
+func (d downloader) copyWithResume(req *http.Request, fileName string, f *os.File) error {
+	URL := req.URL.String()
+	progress := DownloadProgress{
+		URL:        URL,
+		FileName:   fileName,
+		TotalBytes: -1,
+		Started:    time.Now(),
+	}
+	var canResume bool
+	var validator string // used with If-Range, so a changed file is not resumed
+	for resumes := 0; ; resumes++ {
+		thisReq := req
+		if progress.BytesDownloaded > 0 {
+			thisReq = req.Clone(req.Context())
+			thisReq.Header.Set("Range", fmt.Sprintf("bytes=%d-", progress.BytesDownloaded))
+			if validator != "" {
+				thisReq.Header.Set("If-Range", validator)
+			}
+		}
+		resp, err := d.do(thisReq)
+		if err != nil {
+			return err
+		}
+		switch {
+		case resp.StatusCode == http.StatusOK:
+			if progress.BytesDownloaded > 0 {
+				debugLog.Printf("restarting the download of %s, as the server did not resume it", URL)
+				err = restartFile(f)
+				if err != nil {
+					resp.Body.Close()
+					return err
+				}
+				progress.BytesDownloaded = 0
+			}
+			progress.TotalBytes = resp.ContentLength
+			canResume = strings.EqualFold(resp.Header.Get("Accept-Ranges"), "bytes")
+			validator = resp.Header.Get("ETag")
+			if validator == "" || strings.HasPrefix(validator, "W/") {
+				validator = resp.Header.Get("Last-Modified")
+			}
+		case resp.StatusCode == http.StatusPartialContent && progress.BytesDownloaded > 0:
+			start, err := contentRangeStart(resp.Header.Get("Content-Range"))
+			if err != nil || start != progress.BytesDownloaded {
+				resp.Body.Close()
+				return fmt.Errorf("cannot resume the download of %s, the server responded with the range %q instead of starting at byte %d", URL, resp.Header.Get("Content-Range"), progress.BytesDownloaded)
+			}
+		default:
+			resp.Body.Close()
+			return fmt.Errorf("HTTP %d for %s", resp.StatusCode, URL)
+		}
+		_, err = io.Copy(f, &progressReader{r: resp.Body, progress: &progress, report: d.progress})
+		resp.Body.Close()
+		if err == nil && progress.TotalBytes >= 0 && progress.BytesDownloaded < progress.TotalBytes {
+			err = io.ErrUnexpectedEOF
+		}
+		if err == nil {
+			progress.Done = true
+			if d.progress != nil {
+				d.progress(progress)
+			}
+			return nil
+		}
+		if !canResume || resumes >= d.maxResumes || req.Context().Err() != nil {
+			return fmt.Errorf("while downloading %s: %w", URL, err)
+		}
+		debugLog.Printf("resuming the download of %s at byte %d after error: %v", URL, progress.BytesDownloaded, err)
+	}
+}
+
+// restartFile truncates the file, to be written again from the start.
+func restartFile(f *os.File) error {
+	err := f.Truncate(0)
+	if err != nil {
+		return err
+	}
+	_, err = f.Seek(0, io.SeekStart)
+	return err
+}
+
+// contentRangeStart returns the first byte position from a Content-Range
+// header of the form: bytes <start>-<end>/<size>
+func contentRangeStart(contentRange string) (int64, error) {
+	spec, found := strings.CutPrefix(contentRange, "bytes ")
+	if !found {
+		return 0, errors.New("the Content-Range is not in bytes")
+	}
+	start, _, found := strings.Cut(spec, "-")
+	if !found {
+		return 0, errors.New("the Content-Range has no range")
+	}
+	return strconv.ParseInt(start, 10, 64)
+}
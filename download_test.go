@@ -0,0 +1,199 @@
+package jkl_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ivanfetch/jkl"
+)
+
+func TestDownloadResumesAfterDroppedConnection(t *testing.T) {
+	t.Parallel()
+	content := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(content)
+	const dropAfterBytes = 40000
+	testCases := []struct {
+		description     string
+		acceptRanges    bool
+		ignoreRanges    bool // respond with the full content to range requests
+		wantRanges      []string
+		wantErrContains string // an empty string expects no error
+	}{
+		{
+			description:  "resumed using a range request",
+			acceptRanges: true,
+			wantRanges:   []string{"", "bytes=40000-"},
+		},
+		{
+			description:  "restarted when the server ignores the range",
+			acceptRanges: true,
+			ignoreRanges: true,
+			wantRanges:   []string{"", "bytes=40000-"},
+		},
+		{
+			description:     "not resumed when the server does not accept ranges",
+			acceptRanges:    false,
+			wantRanges:      []string{""},
+			wantErrContains: "unexpected EOF",
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			var mu sync.Mutex
+			var gotRanges []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				gotRanges = append(gotRanges, r.Header.Get("Range"))
+				firstRequest := len(gotRanges) == 1
+				mu.Unlock()
+				w.Header().Set("ETag", `"v1"`)
+				if tc.acceptRanges {
+					w.Header().Set("Accept-Ranges", "bytes")
+				}
+				if firstRequest {
+					// Send part of the content, then drop the connection.
+					w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+					w.WriteHeader(http.StatusOK)
+					w.Write(content[:dropAfterBytes])
+					w.(http.Flusher).Flush()
+					conn, _, err := w.(http.Hijacker).Hijack()
+					if err != nil {
+						t.Errorf("hijacking connection: %v", err)
+						return
+					}
+					conn.Close()
+					return
+				}
+				if tc.ignoreRanges {
+					r.Header.Del("Range")
+				}
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+			}))
+			t.Cleanup(server.Close)
+			var events []jkl.DownloadProgress
+			g, err := jkl.NewGithubRepo("jkltest/tool", jkl.WithAPIHost(server.URL), jkl.WithProgress(func(p jkl.DownloadProgress) {
+				events = append(events, p)
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			filePath, err := g.Download(context.Background(), jkl.GithubAsset{Name: "tool.tar.gz", URL: server.URL + "/assets/1"})
+			mu.Lock()
+			defer mu.Unlock()
+			if len(gotRanges) != len(tc.wantRanges) {
+				t.Fatalf("want requests with Range headers %q, got %q", tc.wantRanges, gotRanges)
+			}
+			for i := range tc.wantRanges {
+				if gotRanges[i] != tc.wantRanges[i] {
+					t.Fatalf("want requests with Range headers %q, got %q", tc.wantRanges, gotRanges)
+				}
+			}
+			if tc.wantErrContains != "" {
+				if err == nil || !bytes.Contains([]byte(err.Error()), []byte(tc.wantErrContains)) {
+					t.Fatalf("want an error containing %q, got: %v", tc.wantErrContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(filePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Fatalf("want the downloaded file to have the complete content of %d bytes, got %d bytes", len(content), len(got))
+			}
+			if len(events) == 0 {
+				t.Fatal("want progress events")
+			}
+			lastEvent := events[len(events)-1]
+			if !lastEvent.Done || lastEvent.BytesDownloaded != int64(len(content)) || lastEvent.TotalBytes != int64(len(content)) {
+				t.Fatalf("want a final progress event with all %d bytes downloaded, got %+v", len(content), lastEvent)
+			}
+		})
+	}
+}
+
+func TestCancelledDownloadRemovesPartialFile(t *testing.T) {
+	// Not parallel, to check for temporary files left behind.
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", filepath.Join(tempDir, "tmp"))
+	err := os.Mkdir(filepath.Join(tempDir, "tmp"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Send part of the content, then wait for the client to give up.
+		w.Header().Set("Content-Length", "2000")
+		w.WriteHeader(http.StatusOK)
+		w.Write(make([]byte, 1000))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	cache, err := jkl.NewDownloadCache(filepath.Join(tempDir, "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		description string
+		cache       *jkl.DownloadCache // optional
+		checkDir    string             // should have no files after cancelling
+	}{
+		{
+			description: "temporary directory",
+			checkDir:    filepath.Join(tempDir, "tmp"),
+		},
+		{
+			description: "download cache",
+			cache:       cache,
+			checkDir:    filepath.Join(tempDir, "cache"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			g, err := jkl.NewGithubRepo("jkltest/tool", jkl.WithAPIHost(server.URL), jkl.WithDownloadCache(tc.cache), jkl.WithProgress(func(jkl.DownloadProgress) {
+				cancel()
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = g.Download(ctx, jkl.GithubAsset{Name: "tool.tar.gz", URL: server.URL + "/assets/1"})
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("want a context.Canceled error, got: %v", err)
+			}
+			var leftovers []string
+			err = filepath.WalkDir(tc.checkDir, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if !d.IsDir() {
+					leftovers = append(leftovers, path)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(leftovers) != 0 {
+				t.Errorf("want no files left after a cancelled download, got %v", leftovers)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package jkl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ExecToolVersion exec()s the specified version of a tool, regardless of
+// the PATH environment variable and configuration files. See
+// ToolVersionCommandPath for the forms of tool version that are accepted.
+// The jkl process is replaced by the tool, which receives the specified
+// arguments. Progress of installing the tool is written to output.
+func (j JKL) ExecToolVersion(ctx context.Context, toolVersion string, args []string, output io.Writer) error {
+	commandPath, err := j.ToolVersionCommandPath(ctx, toolVersion, output)
+	if err != nil {
+		return err
+	}
+	return ExecCommand(append([]string{commandPath}, args...))
+}
+
+// ToolVersionCommandPath returns the path to the installed binary of the
+// specified tool version, which is one of:
+//   - A tool name, which selects the latest installed version.
+//   - A tool name and version separated by a colon, where a partial version
+//     matches the latest installed version beginning with it. If automatic
+//     installation is enabled, a version that is not installed is installed
+//     from the provider and source that the tool was previously installed
+//     from.
+//   - A tool-specification of the form provider:source[:version], which is
+//     installed as by the install command, reusing a previously downloaded
+//     release.
+//
+// Progress of installing the tool is written to output.
+func (j JKL) ToolVersionCommandPath(ctx context.Context, toolVersion string, output io.Writer) (string, error) {
+	fields := strings.Split(toolVersion, ":")
+	if len(fields) > 1 && isToolProvider(fields[0]) {
+		toolSpec, err := j.installToolSpec(ctx, toolVersion)
+		if err != nil {
+			return "", err
+		}
+		return j.installedCommandPath(ctx, toolSpec.name, toolSpec.version, output)
+	}
+	if len(fields) > 2 {
+		return "", fmt.Errorf("%q is not a tool name and version of the form <tool name>[:version], or a tool-specification of the form <provider>:<source>[:version]", toolVersion)
+	}
+	var version string
+	if len(fields) == 2 {
+		version = fields[1]
+	}
+	return j.installedCommandPath(ctx, fields[0], version, output)
+}
+
+// installedCommandPath returns the path to the installed binary of the
+// tool, for the installed version matching the specified version. An empty
+// version or "latest" selects the latest installed version. The "system"
+// version, or a version that JKL has not installed, is found in the PATH as
+// described by managedTool.systemCommandPath. Otherwise, a version that is
+// not installed is installed if automatic installation is enabled, writing
+// progress to output.
+func (j JKL) installedCommandPath(ctx context.Context, toolName, version string, output io.Writer) (string, error) {
+	tool := j.getManagedTool(toolName)
+	if isSystemVersion(version) {
+		commandPath, found, err := tool.systemCommandPath(version)
+		if err != nil {
+			return "", err
+		}
+		if !found {
+			return "", fmt.Errorf("%s is not found in PATH outside of %s", toolName, j.shimsDir)
+		}
+		return commandPath, nil
+	}
+	if version == "" || strings.EqualFold(version, "latest") {
+		latestVersion, found, err := tool.latestInstalledVersion()
+		if err != nil {
+			return "", err
+		}
+		if !found {
+			return "", fmt.Errorf("no versions of %s are installed, please see the `%s install` command to install it", toolName, callMeProgName)
+		}
+		version = latestVersion
+	} else {
+		matchedVersion, err := tool.matchVersion(version)
+		if err != nil {
+			commandPath, found, systemErr := tool.systemCommandPath(version)
+			if systemErr == nil && found {
+				return commandPath, nil
+			}
+			if j.autoInstall {
+				commandPath, installErr := j.autoInstallVersion(ctx, tool, version, "", output)
+				if installErr != nil {
+					return "", fmt.Errorf("%v, %v", err, installErr)
+				}
+				return commandPath, nil
+			}
+			return "", fmt.Errorf("%v\nTo install a version and run it, specify a tool-specification of the form <provider>:<source>[:version]", err)
+		}
+		version = matchedVersion
+	}
+	commandPath, found, err := tool.path(version)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", fmt.Errorf("version %s of %s is not installed, please see the `%s install` command to install it", version, toolName, callMeProgName)
+	}
+	return commandPath, nil
+}
@@ -0,0 +1,103 @@
+package jkl_test
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/ivanfetch/jkl"
+)
+
+func TestToolVersionCommandPath(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		description string
+		toolVersion string
+		autoInstall bool
+		wantVersion string
+		expectError bool
+	}{
+		{
+			description: "tool name selects the latest installed version",
+			toolVersion: "tool",
+			wantVersion: "v2.0.0",
+		},
+		{
+			description: "exact version",
+			toolVersion: "tool:v1.0.0",
+			wantVersion: "v1.0.0",
+		},
+		{
+			description: "partial version without a leading v",
+			toolVersion: "tool:1",
+			wantVersion: "v1.1.0",
+		},
+		{
+			description: "latest",
+			toolVersion: "tool:latest",
+			wantVersion: "v2.0.0",
+		},
+		{
+			description: "tool-specification which is installed",
+			toolVersion: "github:jkltest/tool:2.1",
+			wantVersion: "v2.1.0",
+		},
+		{
+			description: "version which is not installed",
+			toolVersion: "tool:2.1",
+			expectError: true,
+		},
+		{
+			description: "version which is not installed is installed automatically",
+			toolVersion: "tool:2.1",
+			autoInstall: true,
+			wantVersion: "v2.1.0",
+		},
+		{
+			description: "tool which is not installed",
+			toolVersion: "othertool",
+			autoInstall: true,
+			expectError: true,
+		},
+		{
+			description: "tool which is not installed and automatic installation is disabled",
+			toolVersion: "othertool",
+			expectError: true,
+		},
+		{
+			description: "too many components",
+			toolVersion: "tool:1:2",
+			expectError: true,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			tempDir := t.TempDir()
+			server := newFakeGithubServer(t, "jkltest/tool", "v1.0.0", "v1.1.0", "v2.0.0", "v2.1.0")
+			j := newTestJKL(t, tempDir, server, jkl.WithAutoInstall(tc.autoInstall))
+			for _, spec := range []string{"github:jkltest/tool:1.0.0", "github:jkltest/tool:1.1.0", "github:jkltest/tool:2.0.0"} {
+				_, err := j.Install(context.Background(), spec)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := j.ToolVersionCommandPath(context.Background(), tc.toolVersion, io.Discard)
+			if err != nil && !tc.expectError {
+				t.Fatal(err)
+			}
+			if err == nil && tc.expectError {
+				t.Fatalf("an error is expected, got path %q", got)
+			}
+			if tc.expectError {
+				return
+			}
+			want := filepath.Join(tempDir, "installs/tool", tc.wantVersion, "tool")
+			if got != want {
+				t.Fatalf("want path %q, got %q", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package jkl
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// elfArchitectures maps ELF machine types to Go architectures.
+var elfArchitectures = map[elf.Machine]string{
+	elf.EM_386:       "386",
+	elf.EM_X86_64:    "amd64",
+	elf.EM_ARM:       "arm",
+	elf.EM_AARCH64:   "arm64",
+	elf.EM_PPC64:     "ppc64",
+	elf.EM_S390:      "s390x",
+	elf.EM_RISCV:     "riscv64",
+	elf.EM_MIPS:      "mips",
+	elf.EM_LOONGARCH: "loong64",
+}
+
+// elfOperatingSystems maps ELF OS ABIs to Go operating systems. The generic
+// System V ABI (ELFOSABI_NONE) is not included, as it is used by binaries
+// for Linux, OpenBSD, NetBSD, Solaris, and others.
+var elfOperatingSystems = map[elf.OSABI]string{
+	elf.ELFOSABI_LINUX:   "linux",
+	elf.ELFOSABI_FREEBSD: "freebsd",
+	elf.ELFOSABI_NETBSD:  "netbsd",
+	elf.ELFOSABI_OPENBSD: "openbsd",
+	elf.ELFOSABI_SOLARIS: "solaris",
+}
+
+// machoArchitectures maps Mach-O CPU types to Go architectures.
+var machoArchitectures = map[macho.Cpu]string{
+	macho.Cpu386:   "386",
+	macho.CpuAmd64: "amd64",
+	macho.CpuArm:   "arm",
+	macho.CpuArm64: "arm64",
+}
+
+// machoArchitecture returns the Go architecture for a Mach-O CPU type.
+func machoArchitecture(cpu macho.Cpu) string {
+	arch, ok := machoArchitectures[cpu]
+	if !ok {
+		return cpu.String()
+	}
+	return arch
+}
+
+// peArchitectures maps PE machine types to Go architectures.
+var peArchitectures = map[uint16]string{
+	pe.IMAGE_FILE_MACHINE_I386:  "386",
+	pe.IMAGE_FILE_MACHINE_AMD64: "amd64",
+	pe.IMAGE_FILE_MACHINE_ARMNT: "arm",
+	pe.IMAGE_FILE_MACHINE_ARM64: "arm64",
+}
+
+// VerifyExecutable returns an error if the specified file is not an
+// executable that runs on the specified operating system and architecture.
+// ELF, Mach-O, and PE binaries are inspected for their platform, and
+// scripts beginning with a shebang (#!) line are allowed on all but Windows.
+func VerifyExecutable(filePath, OS, arch string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	magic := make([]byte, 512)
+	n, err := io.ReadFull(f, magic)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return err
+	}
+	magic = magic[:n]
+	var gotOS string
+	var gotArchs []string
+	switch {
+	case bytes.HasPrefix(magic, []byte("#!")):
+		if strings.EqualFold(OS, "windows") {
+			return errors.New("scripts are not executable on windows")
+		}
+		debugLog.Printf("%s is a script", filePath)
+		return nil
+	case bytes.HasPrefix(magic, []byte(elf.ELFMAG)):
+		elfFile, err := elf.NewFile(f)
+		if err != nil {
+			return fmt.Errorf("invalid ELF binary: %v", err)
+		}
+		elfOS, ok := elfOperatingSystems[elfFile.OSABI]
+		if !ok {
+			elfOS = elfFile.OSABI.String()
+		}
+		if elfFile.OSABI == elf.ELFOSABI_NONE {
+			if strings.EqualFold(OS, "darwin") || strings.EqualFold(OS, "windows") {
+				return fmt.Errorf("the executable is an ELF binary, which does not run on operating system %q", OS)
+			}
+			debugLog.Printf("%s uses the generic System V ABI, which does not specify an operating system", filePath)
+			elfOS = OS
+		}
+		elfArch, ok := elfArchitectures[elfFile.Machine]
+		if !ok {
+			elfArch = elfFile.Machine.String()
+		}
+		if elfArch == "mips" && elfFile.Class == elf.ELFCLASS64 {
+			elfArch = "mips64"
+		}
+		if (elfArch == "ppc64" || strings.HasPrefix(elfArch, "mips")) && elfFile.ByteOrder == binary.LittleEndian {
+			elfArch += "le"
+		}
+		gotOS = elfOS
+		gotArchs = []string{elfArch}
+	case bytes.HasPrefix(magic, []byte{0xca, 0xfe, 0xba, 0xbe}):
+		fatFile, err := macho.NewFatFile(f)
+		if err != nil {
+			return fmt.Errorf("invalid universal Mach-O binary: %v", err)
+		}
+		gotOS = "darwin"
+		for _, fatArch := range fatFile.Arches {
+			gotArchs = append(gotArchs, machoArchitecture(fatArch.Cpu))
+		}
+	case bytes.HasPrefix(magic, []byte{0xcf, 0xfa, 0xed, 0xfe}), bytes.HasPrefix(magic, []byte{0xce, 0xfa, 0xed, 0xfe}):
+		machoFile, err := macho.NewFile(f)
+		if err != nil {
+			return fmt.Errorf("invalid Mach-O binary: %v", err)
+		}
+		gotOS = "darwin"
+		gotArchs = []string{machoArchitecture(machoFile.Cpu)}
+	case bytes.HasPrefix(magic, []byte("MZ")):
+		peFile, err := pe.NewFile(f)
+		if err != nil {
+			return fmt.Errorf("invalid PE binary: %v", err)
+		}
+		gotOS = "windows"
+		peArch, ok := peArchitectures[peFile.Machine]
+		if !ok {
+			peArch = fmt.Sprintf("machine type %#x", peFile.Machine)
+		}
+		gotArchs = []string{peArch}
+	default:
+		return fmt.Errorf("the file is not an executable, it appears to be %s", http.DetectContentType(magic))
+	}
+	debugLog.Printf("%s is an executable for OS %s and architectures %v", filePath, gotOS, gotArchs)
+	if !strings.EqualFold(OS, gotOS) {
+		return fmt.Errorf("the executable is for operating system %q instead of %q", gotOS, OS)
+	}
+	for _, gotArch := range gotArchs {
+		if strings.EqualFold(arch, gotArch) {
+			return nil
+		}
+		if strings.EqualFold(OS, "darwin") && strings.EqualFold(arch, "arm64") && gotArch == "amd64" {
+			// AMD64 binaries run under Mac OS Rosetta.
+			debugLog.Printf("allowing %s for Darwin/ARM64 which can run an AMD64 executable", filePath)
+			return nil
+		}
+	}
+	return fmt.Errorf("the executable is for architecture %q instead of %q", strings.Join(gotArchs, ", "), arch)
+}
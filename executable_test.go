@@ -0,0 +1,110 @@
+package jkl_test
+
+import (
+	"testing"
+
+	"github.com/ivanfetch/jkl"
+)
+
+func TestVerifyExecutable(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		description string
+		filePath    string
+		OS, arch    string
+		expectError bool
+	}{
+		{
+			description: "Linux AMD64 ELF binary",
+			filePath:    "linux-amd64.elf",
+			OS:          "linux",
+			arch:        "amd64",
+		},
+		{
+			description: "Linux ARM64 ELF binary",
+			filePath:    "linux-arm64.elf",
+			OS:          "linux",
+			arch:        "arm64",
+		},
+		{
+			description: "Linux ARM64 ELF binary on AMD64 which will return an error",
+			filePath:    "linux-arm64.elf",
+			OS:          "linux",
+			arch:        "amd64",
+			expectError: true,
+		},
+		{
+			description: "System V ELF binary on OpenBSD",
+			filePath:    "linux-amd64.elf",
+			OS:          "openbsd",
+			arch:        "amd64",
+		},
+		{
+			description: "System V ELF binary on Darwin which will return an error",
+			filePath:    "linux-amd64.elf",
+			OS:          "darwin",
+			arch:        "amd64",
+			expectError: true,
+		},
+		{
+			description: "FreeBSD ELF binary on Linux which will return an error",
+			filePath:    "freebsd-amd64.elf",
+			OS:          "linux",
+			arch:        "amd64",
+			expectError: true,
+		},
+		{
+			description: "Darwin AMD64 Mach-O binary",
+			filePath:    "darwin-amd64.macho",
+			OS:          "darwin",
+			arch:        "amd64",
+		},
+		{
+			description: "Darwin AMD64 Mach-O binary on ARM64 via Rosetta",
+			filePath:    "darwin-amd64.macho",
+			OS:          "darwin",
+			arch:        "arm64",
+		},
+		{
+			description: "Darwin ARM64 Mach-O binary on AMD64 which will return an error",
+			filePath:    "darwin-arm64.macho",
+			OS:          "darwin",
+			arch:        "amd64",
+			expectError: true,
+		},
+		{
+			description: "Darwin Mach-O binary on Linux which will return an error",
+			filePath:    "darwin-amd64.macho",
+			OS:          "linux",
+			arch:        "amd64",
+			expectError: true,
+		},
+		{
+			description: "Shell script",
+			filePath:    "script.sh",
+			OS:          "linux",
+			arch:        "amd64",
+		},
+		{
+			description: "HTML error page which will return an error",
+			filePath:    "error-page.html",
+			OS:          "linux",
+			arch:        "amd64",
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // Capture range variable
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			err := jkl.VerifyExecutable("testdata/executables/"+tc.filePath, tc.OS, tc.arch)
+			if err != nil && !tc.expectError {
+				t.Fatal(err)
+			}
+			if err == nil && tc.expectError {
+				t.Fatal("an error is expected")
+			}
+		})
+	}
+}
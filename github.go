@@ -1,16 +1,16 @@
 package jkl
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"os"
 	"path/filepath"
 	"regexp"
 	"runtime"
 	"strings"
+	"time"
 )
 
 // GithubDownload accepts a type toolSpec and populates it with the path of the
@@ -18,12 +18,12 @@ import (
 // determined by its assets. The toolSpec may also be updated with the
 // version of the tool that was downloaded, in cases where a partial or
 // "latest" version is specified.
-func GithubDownload(TS *ToolSpec) error {
-	g, err := NewGithubRepo(TS.source)
+func GithubDownload(ctx context.Context, TS *ToolSpec, clientOptions ...githubClientOption) error {
+	g, err := NewGithubRepo(TS.source, clientOptions...)
 	if err != nil {
 		return err
 	}
-	downloadPath, downloadVersion, downloadName, err := g.DownloadReleaseForVersion(TS.version)
+	downloadPath, downloadVersion, downloadName, err := g.DownloadReleaseForVersion(ctx, TS.version)
 	if err != nil {
 		return err
 	}
@@ -35,7 +35,13 @@ func GithubDownload(TS *ToolSpec) error {
 
 type GithubClient struct {
 	token, apiHost string
+	tokenIsSet     bool // the token was set by WithToken instead of discovered
 	httpClient     *http.Client
+	downloadCache  *DownloadCache // optional, reuses previously downloaded assets
+	apiCache       *APICache      // optional, stores API responses for offline mode
+	offline        bool           // only use cached API responses and downloads
+	retryPolicy    retryPolicy
+	progress       ProgressFunc // optional, reports download progress
 }
 
 // githubClientOption specifies GithubClient options as functions.
@@ -49,6 +55,17 @@ func WithAPIHost(host string) githubClientOption {
 	}
 }
 
+// WithToken sets the Github token for an instance of GithubClient, instead
+// of discovering a token from the environment. An empty token sends
+// unauthenticated requests.
+func WithToken(token string) githubClientOption {
+	return func(c *GithubClient) error {
+		c.token = token
+		c.tokenIsSet = true
+		return nil
+	}
+}
+
 // WithHTTPClient sets a custom net/http.Client for an instance of GithubClient.
 func WithHTTPClient(hc *http.Client) githubClientOption {
 	return func(c *GithubClient) error {
@@ -57,11 +74,61 @@ func WithHTTPClient(hc *http.Client) githubClientOption {
 	}
 }
 
+// WithDownloadCache sets a DownloadCache for an instance of GithubClient,
+// which stores downloaded assets for reuse.
+func WithDownloadCache(cache *DownloadCache) githubClientOption {
+	return func(c *GithubClient) error {
+		c.downloadCache = cache
+		return nil
+	}
+}
+
+// WithAPICache sets an APICache for an instance of GithubClient, which
+// stores API responses for use in offline mode.
+func WithAPICache(cache *APICache) githubClientOption {
+	return func(c *GithubClient) error {
+		c.apiCache = cache
+		return nil
+	}
+}
+
+// WithOffline sets whether an instance of GithubClient is offline, using
+// only cached API responses and downloads.
+func WithOffline(offline bool) githubClientOption {
+	return func(c *GithubClient) error {
+		c.offline = offline
+		return nil
+	}
+}
+
+// WithRetries sets the number of times an instance of GithubClient retries
+// a request after a connection error or transient server error, and the
+// delay before the first retry, which doubles for each subsequent retry.
+func WithRetries(maxRetries int, baseDelay time.Duration) githubClientOption {
+	return func(c *GithubClient) error {
+		if maxRetries < 0 || baseDelay < 0 {
+			return errors.New("the number of retries and delay cannot be negative")
+		}
+		c.retryPolicy.maxRetries = maxRetries
+		c.retryPolicy.baseDelay = baseDelay
+		return nil
+	}
+}
+
+// WithProgress sets a function for an instance of GithubClient, which
+// receives progress events while assets are downloaded.
+func WithProgress(progress ProgressFunc) githubClientOption {
+	return func(c *GithubClient) error {
+		c.progress = progress
+		return nil
+	}
+}
+
 func NewGithubClient(options ...githubClientOption) (*GithubClient, error) {
 	c := &GithubClient{
-		apiHost:    "https://api.github.com",
-		token:      os.Getenv("GH_TOKEN"),
-		httpClient: &defaultHTTPClient,
+		apiHost:     "https://api.github.com",
+		httpClient:  &defaultHTTPClient,
+		retryPolicy: defaultRetryPolicy,
 	}
 	for _, o := range options {
 		err := o(c)
@@ -69,12 +136,46 @@ func NewGithubClient(options ...githubClientOption) (*GithubClient, error) {
 			return nil, err
 		}
 	}
+	if !c.tokenIsSet {
+		var tokenSource string
+		c.token, tokenSource = discoverGithubToken(c.apiHost)
+		if c.token != "" {
+			debugLog.Printf("using the Github token for %s from %s", c.apiHost, tokenSource)
+		}
+	}
 	return c, nil
 }
 
+// downloader returns a downloader using the options of the GithubClient.
+func (c GithubClient) downloader() downloader {
+	return downloader{
+		do:         c.do,
+		cache:      c.downloadCache,
+		offline:    c.offline,
+		progress:   c.progress,
+		maxResumes: defaultMaxResumes,
+	}
+}
+
+// do sends the HTTP request with retries, returning an error that explains
+// how to proceed if the Github rate limit has been exceeded.
+func (c GithubClient) do(req *http.Request) (*http.Response, error) {
+	resp, err := c.retryPolicy.do(c.httpClient, req)
+	if err != nil {
+		return nil, err
+	}
+	err = githubRateLimitError(resp, c.token != "")
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
 type GithubAsset struct {
-	Name string `json:"name"`
-	URL  string `json:"url"`
+	Name               string `json:"name"`
+	URL                string `json:"url"`
+	BrowserDownloadURL string `json:"browser_download_url"` // on the Github web host, such as https://github.com/<owner>/<repository>/releases/download/<tag>/<name>
 }
 
 var versionRE *regexp.Regexp = regexp.MustCompile(`(.+?)[-_]?v?[-_]?\d+.*`)
@@ -99,7 +200,7 @@ func (g GithubAsset) NameWithoutVersionAndComponents(components ...string) strin
 	}
 	// Attemptto strip a file extension because the above regular expression
 	// failed.
-	for _, ext := range []string{".tar.gz", ".tar", ".tgz", ".tar.bz2", ".zip"} {
+	for _, ext := range []string{".tar.gz", ".tar.xz", ".tar.zst", ".tar", ".tgz", ".txz", ".tar.bz2", ".zip"} {
 		strippedName = strings.Replace(strippedName, ext, "", -1)
 	}
 	debugLog.Printf("the stripped name is %q", strippedName)
@@ -184,14 +285,37 @@ type GithubRepo struct {
 	client       *GithubClient
 }
 
+// SplitGithubSource splits a Github source of the form
+// [Host/]OwnerName/RepositoryName into its optional host, and the owner and
+// repository.
+func SplitGithubSource(source string) (host, ownerAndRepo string, err error) {
+	if source == "" {
+		return "", "", errors.New("the repository cannot be empty, please specify a repository of the form OwnerName/RepositoryName")
+	}
+	components := strings.Split(source, "/")
+	switch len(components) {
+	case 2:
+		return "", source, nil
+	case 3:
+		return strings.ToLower(components[0]), components[1] + "/" + components[2], nil
+	default:
+		return "", "", fmt.Errorf("the repository %q must be of the form OwnerName/RepositoryName, optionally preceded by a Github Enterprise hostname", source)
+	}
+}
+
+// NewGithubRepo constructs a GithubRepo for a repository of the form
+// [Host/]OwnerName/RepositoryName. A host other than github.com is assumed
+// to be Github Enterprise Server, with its API at https://<host>/api/v3,
+// which can be overridden by WithAPIHost.
 func NewGithubRepo(ownerAndRepo string, clientOptions ...githubClientOption) (*GithubRepo, error) {
-	if ownerAndRepo == "" {
-		return nil, errors.New("the repository cannot be empty, please specify a repository of the form OwnerName/RepositoryName")
+	host, ownerAndRepo, err := SplitGithubSource(ownerAndRepo)
+	if err != nil {
+		return nil, err
 	}
-	if !strings.Contains(ownerAndRepo, "/") {
-		return nil, errors.New("the repository must be of the form OwnerName/RepositoryName")
+	if host != "" && host != "github.com" {
+		debugLog.Printf("using Github Enterprise Server %s for repository %s", host, ownerAndRepo)
+		clientOptions = append([]githubClientOption{WithAPIHost("https://" + host + "/api/v3")}, clientOptions...)
 	}
-	ownerAndRepo = strings.Replace(ownerAndRepo, "github.com/", "", 1)
 	c, err := NewGithubClient(clientOptions...)
 	if err != nil {
 		return nil, fmt.Errorf("while constructing Github client for repository %s: %w", ownerAndRepo, err)
@@ -206,9 +330,9 @@ func (g GithubRepo) GetOwnerAndRepo() string {
 	return g.ownerAndRepo
 }
 
-func (g GithubRepo) Exists() (bool, error) {
+func (g GithubRepo) Exists(ctx context.Context) (bool, error) {
 	URI := "/repos/" + g.ownerAndRepo
-	resp, err := g.githubAPIRequest(http.MethodGet, URI)
+	resp, err := g.githubAPIRequest(ctx, http.MethodGet, URI)
 	if err != nil {
 		return false, err
 	}
@@ -221,27 +345,27 @@ func (g GithubRepo) Exists() (bool, error) {
 	return false, fmt.Errorf("HTTP %d for %s", resp.StatusCode, URI)
 }
 
-func (g *GithubRepo) githubAPIRequest(method, URI string) (*http.Response, error) {
+func (g *GithubRepo) githubAPIRequest(ctx context.Context, method, URI string) (*http.Response, error) {
 	if !strings.HasPrefix(URI, "/") {
 		URI = "/" + URI
 	}
 	URL := g.client.apiHost + URI + "?per_page=100"
-	req, err := http.NewRequest(method, URL, nil)
+	req, err := http.NewRequestWithContext(ctx, method, URL, nil)
 	if err != nil {
 		return nil, err
 	}
 	if g.client.token != "" {
 		req.Header.Add("Authorization", fmt.Sprintf("token %s", g.client.token))
 	}
-	resp, err := g.client.httpClient.Do(req)
+	resp, err := doAPIRequest(g.client.do, req, g.client.apiCache, g.client.offline)
 	if err != nil {
 		return nil, err
 	}
 	return resp, nil
 }
 
-func (g GithubRepo) AssetsForTag(tag string) ([]GithubAsset, error) {
-	ok, err := g.Exists()
+func (g GithubRepo) AssetsForTag(ctx context.Context, tag string) ([]GithubAsset, error) {
+	ok, err := g.Exists(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -249,7 +373,7 @@ func (g GithubRepo) AssetsForTag(tag string) ([]GithubAsset, error) {
 		return nil, errors.New("no such repository")
 	}
 	URI := "/repos/" + g.ownerAndRepo + "/releases/tags/" + tag
-	resp, err := g.githubAPIRequest(http.MethodGet, URI)
+	resp, err := g.githubAPIRequest(ctx, http.MethodGet, URI)
 	if err != nil {
 		return nil, err
 	}
@@ -270,8 +394,8 @@ func (g GithubRepo) AssetsForTag(tag string) ([]GithubAsset, error) {
 	return APIResp.Assets, nil
 }
 
-func (g GithubRepo) GetTagForLatestRelease() (tagName string, err error) {
-	ok, err := g.Exists()
+func (g GithubRepo) GetTagForLatestRelease(ctx context.Context) (tagName string, err error) {
+	ok, err := g.Exists(ctx)
 	if err != nil {
 		return "", err
 	}
@@ -279,7 +403,7 @@ func (g GithubRepo) GetTagForLatestRelease() (tagName string, err error) {
 		return "", errors.New("no such repository")
 	}
 	URI := "/repos/" + g.ownerAndRepo + "/releases/latest"
-	resp, err := g.githubAPIRequest(http.MethodGet, URI)
+	resp, err := g.githubAPIRequest(ctx, http.MethodGet, URI)
 	if err != nil {
 		return "", err
 	}
@@ -300,8 +424,17 @@ func (g GithubRepo) GetTagForLatestRelease() (tagName string, err error) {
 	return *APIResp.TagName, nil
 }
 
-func (g GithubRepo) Download(asset GithubAsset) (filePath string, err error) {
-	req, err := http.NewRequest(http.MethodGet, asset.URL, nil)
+// Download downloads the release asset using the Github API. If a configured
+// mirror matches the browser download URL of the asset instead, that URL is
+// downloaded so it is rewritten to the mirror, as the API URL redirects to
+// a host that mirrors for github.com would not match.
+func (g GithubRepo) Download(ctx context.Context, asset GithubAsset) (filePath string, err error) {
+	URL := asset.URL
+	if asset.BrowserDownloadURL != "" && isMirrored(g.client.httpClient, asset.BrowserDownloadURL) {
+		debugLog.Printf("downloading %s instead of %s, as it is mirrored", asset.BrowserDownloadURL, asset.URL)
+		URL = asset.BrowserDownloadURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, URL, nil)
 	if err != nil {
 		return "", err
 	}
@@ -309,28 +442,7 @@ func (g GithubRepo) Download(asset GithubAsset) (filePath string, err error) {
 		req.Header.Add("Authorization", fmt.Sprintf("token %s", g.client.token))
 	}
 	req.Header.Add("Accept", "application/octet-stream")
-	resp, err := g.client.httpClient.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("HTTP %d for %s", resp.StatusCode, asset.URL)
-	}
-	tempDir, err := os.MkdirTemp(os.TempDir(), callMeProgName+"-")
-	if err != nil {
-		return "", err
-	}
-	filePath = fmt.Sprintf("%s/%s", tempDir, asset.Name)
-	f, err := os.Create(filePath)
-	if err != nil {
-		return "", err
-	}
-	defer f.Close()
-	if _, err := io.Copy(f, resp.Body); err != nil {
-		return "", err
-	}
-	return filePath, nil
+	return g.client.downloader().download(req, asset.Name)
 }
 
 // DownloadReleaseForVersion matches a Github release tag for the
@@ -338,31 +450,31 @@ func (g GithubRepo) Download(asset GithubAsset) (filePath string, err error) {
 // The release tag is matched from the specified version using
 // findGithubReleaseTagForVersion().
 // An empty version causes the latest release to be installed.
-func (g GithubRepo) DownloadReleaseForVersion(version string) (binaryPath, matchedTag, assetBaseName string, err error) {
-	tag, ok, err := g.findTagForVersion(version)
+func (g GithubRepo) DownloadReleaseForVersion(ctx context.Context, version string) (binaryPath, matchedTag, assetBaseName string, err error) {
+	tag, ok, err := g.findTagForVersion(ctx, version)
 	if err != nil {
 		return "", "", "", err
 	}
 	if !ok {
 		return "", "", "", fmt.Errorf("no tag found matching version %q", version)
 	}
-	binaryPath, assetBaseName, err = g.DownloadReleaseForTag(tag)
+	binaryPath, assetBaseName, err = g.DownloadReleaseForTag(ctx, tag)
 	return binaryPath, tag, assetBaseName, err
 }
 
 // findTagForVersion matches a release tag to the specified version. An empty
 // version or "latest" will return the latest release tag.
-func (g GithubRepo) findTagForVersion(version string) (tag string, found bool, err error) {
+func (g GithubRepo) findTagForVersion(ctx context.Context, version string) (tag string, found bool, err error) {
 	debugLog.Printf("finding Github tag matching version %q of %q\n", version, g.GetOwnerAndRepo())
 	if version == "" || strings.EqualFold(version, "latest") {
-		tag, err = g.GetTagForLatestRelease()
+		tag, err = g.GetTagForLatestRelease(ctx)
 		if err != nil {
 			return "", false, err
 		}
 		return tag, true, nil
 	}
 	URI := "/repos/" + g.ownerAndRepo + "/releases"
-	resp, err := g.githubAPIRequest(http.MethodGet, URI)
+	resp, err := g.githubAPIRequest(ctx, http.MethodGet, URI)
 	if err != nil {
 		return "", false, err
 	}
@@ -401,17 +513,17 @@ func (g GithubRepo) findTagForVersion(version string) (tag string, found bool, e
 	return "", false, nil
 }
 
-func (g GithubRepo) DownloadReleaseForLatest() (binaryPath, latestVersionTag, assetBaseName string, err error) {
-	latestVersionTag, err = g.GetTagForLatestRelease()
+func (g GithubRepo) DownloadReleaseForLatest(ctx context.Context) (binaryPath, latestVersionTag, assetBaseName string, err error) {
+	latestVersionTag, err = g.GetTagForLatestRelease(ctx)
 	if err != nil {
 		return "", "", "", err
 	}
-	binaryPath, assetBaseName, err = g.DownloadReleaseForTag(latestVersionTag)
+	binaryPath, assetBaseName, err = g.DownloadReleaseForTag(ctx, latestVersionTag)
 	return binaryPath, latestVersionTag, assetBaseName, err
 }
 
-func (g GithubRepo) DownloadReleaseForTagOSAndArch(tag, OS, arch string) (filePath, baseAssetName string, err error) {
-	assets, err := g.AssetsForTag(tag)
+func (g GithubRepo) DownloadReleaseForTagOSAndArch(ctx context.Context, tag, OS, arch string) (filePath, baseAssetName string, err error) {
+	assets, err := g.AssetsForTag(ctx, tag)
 	if err != nil {
 		return "", "", err
 	}
@@ -419,16 +531,16 @@ func (g GithubRepo) DownloadReleaseForTagOSAndArch(tag, OS, arch string) (filePa
 	if !ok {
 		return "", "", fmt.Errorf("no asset found matching Github owner/repository %s, tag %s, OS %s, and architecture %s", g.ownerAndRepo, tag, OS, arch)
 	}
-	filePath, err = g.Download(asset)
+	filePath, err = g.Download(ctx, asset)
 	if err != nil {
 		return "", "", err
 	}
 	return filePath, asset.NameWithoutVersionAndComponents(matchedOS, matchedArch, tag), nil
 }
 
-func (g GithubRepo) DownloadReleaseForTag(tag string) (binaryPath, assetBaseName string, err error) {
+func (g GithubRepo) DownloadReleaseForTag(ctx context.Context, tag string) (binaryPath, assetBaseName string, err error) {
 	debugLog.Printf("downloading Github release %q for tag %q\n", g.ownerAndRepo, tag)
-	downloadedFile, assetBaseName, err := g.DownloadReleaseForTagOSAndArch(tag, runtime.GOOS, runtime.GOARCH)
+	downloadedFile, assetBaseName, err := g.DownloadReleaseForTagOSAndArch(ctx, tag, runtime.GOOS, runtime.GOARCH)
 	if err != nil {
 		return "", "", err
 	}
@@ -437,9 +549,9 @@ func (g GithubRepo) DownloadReleaseForTag(tag string) (binaryPath, assetBaseName
 
 // DownloadHelmBinaryForTag downloads a Helm binary from the Helm CDN, for the
 // specified version tag.
-func (g GithubRepo) DownloadHelmBinaryForTag(tag string) (binaryPath string, err error) {
+func (g GithubRepo) DownloadHelmBinaryForTag(ctx context.Context, tag string) (binaryPath string, err error) {
 	URL := fmt.Sprintf("https://get.helm.sh/helm-%s-%s-%s.tar.gz", tag, runtime.GOOS, runtime.GOARCH)
-	binaryPath, err = g.DownloadExternalAsset(URL)
+	binaryPath, err = g.DownloadExternalAsset(ctx, URL)
 	if err != nil {
 		return "", err
 	}
@@ -447,35 +559,14 @@ func (g GithubRepo) DownloadHelmBinaryForTag(tag string) (binaryPath string, err
 }
 
 // DownloadExternalAsset returns the path to a file after downloading it from the specified
-// URL. The file is saved in a created temporary directory.
-func (g GithubRepo) DownloadExternalAsset(URL string) (filePath string, err error) {
-	req, err := http.NewRequest(http.MethodGet, URL, nil)
-	if err != nil {
-		return "", err
-	}
-	resp, err := g.client.httpClient.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("HTTP %d for %s", resp.StatusCode, URL)
-	}
-	tempDir, err := os.MkdirTemp(os.TempDir(), callMeProgName+"-")
+// URL. The file is saved in the download cache if the client has one,
+// otherwise in a created temporary directory.
+func (g GithubRepo) DownloadExternalAsset(ctx context.Context, URL string) (filePath string, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, URL, nil)
 	if err != nil {
 		return "", err
 	}
-	filePath = fmt.Sprintf("%s/%s", tempDir, filepath.Base(URL))
-	f, err := os.Create(filePath)
-	if err != nil {
-		return "", err
-	}
-	defer f.Close()
-	if _, err := io.Copy(f, resp.Body); err != nil {
-		return "", err
-	}
-	debugLog.Printf("downloaded %s to %s", URL, filePath)
-	return filePath, nil
+	return g.client.downloader().download(req, filepath.Base(URL))
 }
 
 func MatchAssetByOsAndArch(assets []GithubAsset, OS, arch string) (matchedAsset GithubAsset, matchedOS, matchedArch string, successfulMatch bool) {
@@ -491,6 +582,20 @@ func MatchAssetByOsAndArch(assets []GithubAsset, OS, arch string) (matchedAsset
 			return asset, "linux64", "linux64", true // OS and arch are linux64 to facilitate stripping components from the asset name
 		}
 	}
+	if strings.EqualFold(OS, "linux") {
+		// Debian and RPM packages often only include the architecture in their
+		// name.
+		for _, asset := range assets {
+			if !isPackageAsset(asset.Name) {
+				continue
+			}
+			matchedArch, foundArch := stringContainsOneOf(asset.Name, arch, getAliasesForArchitecture(arch)...)
+			if foundArch {
+				debugLog.Printf("matched this package asset for arch %q: %#v", arch, asset)
+				return asset, OS, matchedArch, true
+			}
+		}
+	}
 	if strings.EqualFold(OS, "darwin") && strings.EqualFold(arch, "arm64") {
 		// If no Darwin/ARM64 asset is available, try AMD64 which can run under Mac OS
 		// Rosetta.
@@ -499,3 +604,10 @@ func MatchAssetByOsAndArch(assets []GithubAsset, OS, arch string) (matchedAsset
 	}
 	return GithubAsset{}, "", "", false
 }
+
+// isPackageAsset returns true if the asset name has the extension of a
+// Debian or RPM package.
+func isPackageAsset(name string) bool {
+	LCName := strings.ToLower(name)
+	return strings.HasSuffix(LCName, ".deb") || strings.HasSuffix(LCName, ".rpm")
+}
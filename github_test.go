@@ -191,3 +191,124 @@ func TestMatchAssetByOsAndArch(t *testing.T) {
 		t.Fatalf("want architecture %s, got %s", wantArch, gotArch)
 	}
 }
+
+func TestMatchAssetByOsAndArchForPackages(t *testing.T) {
+	t.Parallel()
+	testAssets := []jkl.GithubAsset{
+		{Name: "checksums.txt"},
+		{Name: "tool_1.2.3_darwin_amd64.tar.gz"},
+		{Name: "tool_1.2.3_amd64.deb"},
+		{Name: "tool_1.2.3_arm64.deb"},
+		{Name: "tool-1.2.3-1.aarch64.rpm"},
+	}
+	testCases := []struct {
+		description string
+		OS, arch    string
+		wantAsset   string
+		wantBase    string
+		expectMatch bool
+	}{
+		{
+			description: "Debian package for linux amd64",
+			OS:          "linux",
+			arch:        "amd64",
+			wantAsset:   "tool_1.2.3_amd64.deb",
+			wantBase:    "tool",
+			expectMatch: true,
+		},
+		{
+			description: "Debian package for linux arm64",
+			OS:          "linux",
+			arch:        "arm64",
+			wantAsset:   "tool_1.2.3_arm64.deb",
+			wantBase:    "tool",
+			expectMatch: true,
+		},
+		{
+			description: "packages are not matched for other operating systems",
+			OS:          "windows",
+			arch:        "amd64",
+			expectMatch: false,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc // Capture range variable
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			gotAsset, gotOS, gotArch, ok := jkl.MatchAssetByOsAndArch(testAssets, tc.OS, tc.arch)
+			if ok != tc.expectMatch {
+				t.Fatalf("want match %v, got %v for asset %q", tc.expectMatch, ok, gotAsset.Name)
+			}
+			if !ok {
+				return
+			}
+			if gotAsset.Name != tc.wantAsset {
+				t.Fatalf("want asset %q, got %q", tc.wantAsset, gotAsset.Name)
+			}
+			gotBase := gotAsset.NameWithoutVersionAndComponents(gotOS, gotArch, "v1.2.3")
+			if gotBase != tc.wantBase {
+				t.Fatalf("want base name %q, got %q", tc.wantBase, gotBase)
+			}
+		})
+	}
+}
+
+func TestSplitGithubSource(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		description      string
+		source           string
+		wantHost         string
+		wantOwnerAndRepo string
+		expectError      bool
+	}{
+		{
+			description:      "owner and repository",
+			source:           "ivanfetch/jkl",
+			wantOwnerAndRepo: "ivanfetch/jkl",
+		},
+		{
+			description:      "github.com host",
+			source:           "github.com/ivanfetch/jkl",
+			wantHost:         "github.com",
+			wantOwnerAndRepo: "ivanfetch/jkl",
+		},
+		{
+			description:      "Github Enterprise host",
+			source:           "GHE.example.com/team/tool",
+			wantHost:         "ghe.example.com",
+			wantOwnerAndRepo: "team/tool",
+		},
+		{
+			description: "repository without owner",
+			source:      "jkl",
+			expectError: true,
+		},
+		{
+			description: "too many components",
+			source:      "ghe.example.com/team/tool/extra",
+			expectError: true,
+		},
+		{
+			description: "empty source",
+			source:      "",
+			expectError: true,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			gotHost, gotOwnerAndRepo, err := jkl.SplitGithubSource(tc.source)
+			if tc.expectError && err == nil {
+				t.Fatal("an error was expected")
+			}
+			if !tc.expectError && err != nil {
+				t.Fatal(err)
+			}
+			if gotHost != tc.wantHost || gotOwnerAndRepo != tc.wantOwnerAndRepo {
+				t.Fatalf("want host %q and repository %q, got %q and %q", tc.wantHost, tc.wantOwnerAndRepo, gotHost, gotOwnerAndRepo)
+			}
+		})
+	}
+}
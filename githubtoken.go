@@ -0,0 +1,208 @@
+package jkl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	"gopkg.in/yaml.v3"
+)
+
+/*
+A Github token is discovered from the first of these sources which provides
+one for the Github API host:
+
+ 1. For github.com, the GH_TOKEN or GITHUB_TOKEN environment variables. For
+    Github Enterprise Server, the GH_ENTERPRISE_TOKEN or
+    GITHUB_ENTERPRISE_TOKEN environment variables instead, so a github.com
+    token is not sent to other hosts.
+ 2. The credential-helper command in the JKL_GITHUB_CREDENTIAL_HELPER
+    environment variable, which is run with the Github hostname as its last
+    argument, and outputs the token.
+ 3. The hosts.yml file of the gh CLI, in $GH_CONFIG_DIR,
+    $XDG_CONFIG_HOME/gh, or ~/.config/gh.
+ 4. The netrc file named by the NETRC environment variable, or ~/.netrc.
+
+Tokens are never included in debug output, only where they came from.
+*/
+
+// githubTokenHosts returns the hostnames used to look up a token for the
+// specified Github API host. The public API at api.github.com uses tokens
+// for github.com, and Github Enterprise Server uses the same hostname for
+// its API.
+func githubTokenHosts(apiHost string) []string {
+	u, err := url.Parse(apiHost)
+	if err != nil || u.Host == "" {
+		return []string{apiHost}
+	}
+	hosts := []string{u.Host}
+	if u.Hostname() != u.Host {
+		hosts = append(hosts, u.Hostname())
+	}
+	if strings.EqualFold(u.Hostname(), "api.github.com") {
+		hosts = append(hosts, "github.com")
+	}
+	return hosts
+}
+
+// isPublicGithubAPI returns true if the API host is api.github.com.
+func isPublicGithubAPI(apiHost string) bool {
+	u, err := url.Parse(apiHost)
+	return err == nil && strings.EqualFold(u.Hostname(), "api.github.com")
+}
+
+// discoverGithubToken returns a token for the specified Github API host,
+// and a description of where the token was found.
+func discoverGithubToken(apiHost string) (token, source string) {
+	envVars := []string{"GH_TOKEN", "GITHUB_TOKEN"}
+	if !isPublicGithubAPI(apiHost) {
+		envVars = []string{"GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN"}
+	}
+	for _, envVar := range envVars {
+		token = strings.TrimSpace(os.Getenv(envVar))
+		if token != "" {
+			return token, "the " + envVar + " environment variable"
+		}
+	}
+	hosts := githubTokenHosts(apiHost)
+	if helper := os.Getenv("JKL_GITHUB_CREDENTIAL_HELPER"); helper != "" {
+		// The last host is github.com for the public API, or the hostname
+		// without a port.
+		token, err := githubTokenFromCredentialHelper(helper, hosts[len(hosts)-1])
+		if err != nil {
+			debugLog.Printf("ignoring the Github credential helper: %v", err)
+		}
+		if token != "" {
+			return token, "the credential helper " + helper
+		}
+	}
+	for _, host := range hosts {
+		token, fileName, err := githubTokenFromGHHostsFile(host)
+		if err != nil {
+			debugLog.Printf("ignoring the gh CLI hosts file: %v", err)
+		}
+		if token != "" {
+			return token, "the gh CLI configuration " + fileName
+		}
+	}
+	for _, host := range hosts {
+		token, fileName, err := githubTokenFromNetrc(host)
+		if err != nil {
+			debugLog.Printf("ignoring the netrc file: %v", err)
+		}
+		if token != "" {
+			return token, "the netrc file " + fileName
+		}
+	}
+	return "", ""
+}
+
+// githubTokenFromCredentialHelper runs the credential-helper command with
+// the hostname as an additional argument, returning the first line of its
+// output.
+func githubTokenFromCredentialHelper(helper, host string) (string, error) {
+	args := strings.Fields(helper)
+	cmd := exec.Command(args[0], append(args[1:], host)...)
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("while running %q: %v", helper, err)
+	}
+	token, _, _ := strings.Cut(string(output), "\n")
+	return strings.TrimSpace(token), nil
+}
+
+// ghHostsFilePath returns the path of the gh CLI hosts.yml file.
+func ghHostsFilePath() (string, error) {
+	if configDir := os.Getenv("GH_CONFIG_DIR"); configDir != "" {
+		return filepath.Join(configDir, "hosts.yml"), nil
+	}
+	if XDGConfigHome := os.Getenv("XDG_CONFIG_HOME"); XDGConfigHome != "" {
+		return filepath.Join(XDGConfigHome, "gh", "hosts.yml"), nil
+	}
+	return homedir.Expand("~/.config/gh/hosts.yml")
+}
+
+// githubTokenFromGHHostsFile returns the oauth_token for the specified host
+// from the gh CLI hosts.yml file. Recent versions of gh may store the token
+// in the system keyring instead, in which case no token is returned.
+func githubTokenFromGHHostsFile(host string) (token, fileName string, err error) {
+	fileName, err = ghHostsFilePath()
+	if err != nil {
+		return "", "", err
+	}
+	b, err := os.ReadFile(fileName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fileName, nil
+	}
+	if err != nil {
+		return "", fileName, err
+	}
+	var hostsConfig map[string]struct {
+		OAuthToken string `yaml:"oauth_token"`
+	}
+	err = yaml.Unmarshal(b, &hostsConfig)
+	if err != nil {
+		return "", fileName, fmt.Errorf("cannot parse %s: %v", fileName, err)
+	}
+	for configHost, hostConfig := range hostsConfig {
+		if strings.EqualFold(configHost, host) {
+			return hostConfig.OAuthToken, fileName, nil
+		}
+	}
+	return "", fileName, nil
+}
+
+// netrcFilePath returns the path of the netrc file.
+func netrcFilePath() (string, error) {
+	if netrc := os.Getenv("NETRC"); netrc != "" {
+		return netrc, nil
+	}
+	return homedir.Expand("~/.netrc")
+}
+
+// githubTokenFromNetrc returns the password for the specified machine from
+// the netrc file.
+func githubTokenFromNetrc(host string) (token, fileName string, err error) {
+	fileName, err = netrcFilePath()
+	if err != nil {
+		return "", "", err
+	}
+	f, err := os.Open(fileName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fileName, nil
+	}
+	if err != nil {
+		return "", fileName, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Split(bufio.ScanWords)
+	var inMachine bool
+	for scanner.Scan() {
+		switch scanner.Text() {
+		case "machine":
+			if !scanner.Scan() {
+				break
+			}
+			inMachine = strings.EqualFold(scanner.Text(), host)
+		case "default":
+			inMachine = false
+		case "password":
+			if !scanner.Scan() {
+				break
+			}
+			if inMachine {
+				return scanner.Text(), fileName, nil
+			}
+		}
+	}
+	return "", fileName, scanner.Err()
+}
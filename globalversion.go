@@ -0,0 +1,114 @@
+package jkl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// globalVersion returns the version of the tool set using `jkl global`,
+// which is used when no other version is configured, and its line number in
+// the global versions file.
+func (t managedTool) globalVersion() (version string, line int, found bool, err error) {
+	version, line, found, err = getToolVersionFromASDFConfigFile(t.jkl.globalVersionsFile, t.name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", 0, false, nil
+	}
+	if err != nil {
+		return "", 0, false, fmt.Errorf("while reading global versions: %v", err)
+	}
+	return version, line, found, nil
+}
+
+// GlobalVersion returns the global version of the specified tool, which is
+// used when neither its environment variable nor an ASDF .tool-versions file
+// specifies a version.
+func (j JKL) GlobalVersion(toolName string) (version string, found bool, err error) {
+	version, _, found, err = j.getManagedTool(toolName).globalVersion()
+	return version, found, err
+}
+
+// SetGlobalVersion sets the global version of the specified tool, returning
+// the installed version that was matched. A partial version matches the
+// latest installed version beginning with it, and `latest` always uses the
+// latest installed version.
+func (j JKL) SetGlobalVersion(ctx context.Context, toolName, version string) (matchedVersion string, err error) {
+	matchedVersion, err = j.getManagedTool(toolName).matchVersion(version)
+	if err != nil {
+		return "", err
+	}
+	globalLock, err := j.lock(ctx, "global-versions")
+	if err != nil {
+		return "", err
+	}
+	defer globalLock.unlock()
+	err = WriteASDFToolVersion(j.globalVersionsFile, toolName, matchedVersion)
+	if err != nil {
+		return "", fmt.Errorf("cannot set the global version of %s: %v", toolName, err)
+	}
+	return matchedVersion, nil
+}
+
+// UnsetGlobalVersion removes the global version of the specified tool.
+func (j JKL) UnsetGlobalVersion(ctx context.Context, toolName string) error {
+	_, found, err := j.GlobalVersion(toolName)
+	if err != nil || !found {
+		return err
+	}
+	globalLock, err := j.lock(ctx, "global-versions")
+	if err != nil {
+		return err
+	}
+	defer globalLock.unlock()
+	err = WriteASDFToolVersion(j.globalVersionsFile, toolName, "")
+	if err != nil {
+		return fmt.Errorf("cannot unset the global version of %s: %v", toolName, err)
+	}
+	return nil
+}
+
+// matchVersion returns the installed version of the tool which matches the
+// specified version, for use in configuration. A version of `latest` is
+// returned unchanged, so the latest installed version is always used, as is
+// the `system` version which is not installed by JKL.
+func (t managedTool) matchVersion(version string) (matchedVersion string, err error) {
+	if isSystemVersion(version) {
+		return "system", nil
+	}
+	versions, found, err := t.listInstalledVersions()
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", fmt.Errorf("no versions of %s are installed, please see the `%s install` command to install it", t.name, callMeProgName)
+	}
+	if strings.EqualFold(version, "latest") {
+		return "latest", nil
+	}
+	matchedVersion, ok := matchInstalledVersion(versions, version)
+	if !ok {
+		return "", fmt.Errorf("version %s of %s is not installed, installed versions are: %s", version, t.name, strings.Join(versions, ", "))
+	}
+	return matchedVersion, nil
+}
+
+// matchInstalledVersion returns the installed version which matches the
+// specified version, with or without a leading v. A partial version such as
+// 1.5 matches the latest installed version beginning with 1.5.
+// The installed versions are sorted oldest first.
+func matchInstalledVersion(installedVersions []string, version string) (matchedVersion string, found bool) {
+	for _, installedVersion := range installedVersions {
+		if installedVersion == version || installedVersion == toggleVPrefix(version) {
+			return installedVersion, true
+		}
+	}
+	partialVersion := strings.TrimPrefix(version, "v") + "."
+	for i := len(installedVersions) - 1; i >= 0; i-- {
+		if strings.HasPrefix(strings.TrimPrefix(installedVersions[i], "v"), partialVersion) {
+			return installedVersions[i], true
+		}
+	}
+	return "", false
+}
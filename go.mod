@@ -2,16 +2,19 @@ module github.com/ivanfetch/jkl
 
 // replace github.com/ivanfetch/jkl => ./
 
-go 1.20
+go 1.22
 
 require (
 	github.com/google/go-cmp v0.5.7
 	github.com/h2non/filetype v1.1.3
 	github.com/hashicorp/go-multierror v1.1.1
 	github.com/hashicorp/go-version v1.6.0
+	github.com/klauspost/compress v1.18.0
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/rogpeppe/go-internal v1.10.0
 	github.com/spf13/cobra v1.6.1
+	github.com/ulikunitz/xz v0.5.15
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
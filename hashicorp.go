@@ -1,27 +1,27 @@
 package jkl
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"os"
 	"path/filepath"
 	"runtime"
 	"sort"
 	"strings"
+	"time"
 )
 
 // HashicorpDownload accepts a type toolSpec and populates it with the path of the
 // downloaded file and name of the tool. The version that was downloaded may also be updated, in cases where a partial or
 // "latest" version is specified.
-func HashicorpDownload(TS *ToolSpec) error {
-	h, err := NewHashicorpProduct(TS.source)
+func HashicorpDownload(ctx context.Context, TS *ToolSpec, clientOptions ...hashicorpClientOption) error {
+	h, err := NewHashicorpProduct(TS.source, clientOptions...)
 	if err != nil {
 		return err
 	}
-	downloadPath, downloadVersion, err := h.DownloadReleaseForVersion(TS.version)
+	downloadPath, downloadVersion, err := h.DownloadReleaseForVersion(ctx, TS.version)
 	if err != nil {
 		return err
 	}
@@ -32,8 +32,13 @@ func HashicorpDownload(TS *ToolSpec) error {
 }
 
 type HashicorpClient struct {
-	httpClient *http.Client
-	apiHost    string
+	httpClient    *http.Client
+	apiHost       string
+	downloadCache *DownloadCache // optional, reuses previously downloaded builds
+	apiCache      *APICache      // optional, stores API responses for offline mode
+	offline       bool           // only use cached API responses and downloads
+	retryPolicy   retryPolicy
+	progress      ProgressFunc // optional, reports download progress
 }
 
 // hashicorpClientOption specifies HashicorpClient options as functions.
@@ -61,10 +66,62 @@ func WithHashicorpHTTPClient(hc *http.Client) hashicorpClientOption {
 	}
 }
 
+// WithHashicorpDownloadCache sets a DownloadCache for an instance of
+// HashicorpClient, which stores downloaded builds for reuse.
+func WithHashicorpDownloadCache(cache *DownloadCache) hashicorpClientOption {
+	return func(c *HashicorpClient) error {
+		c.downloadCache = cache
+		return nil
+	}
+}
+
+// WithHashicorpAPICache sets an APICache for an instance of HashicorpClient,
+// which stores API responses for use in offline mode.
+func WithHashicorpAPICache(cache *APICache) hashicorpClientOption {
+	return func(c *HashicorpClient) error {
+		c.apiCache = cache
+		return nil
+	}
+}
+
+// WithHashicorpOffline sets whether an instance of HashicorpClient is
+// offline, using only cached API responses and downloads.
+func WithHashicorpOffline(offline bool) hashicorpClientOption {
+	return func(c *HashicorpClient) error {
+		c.offline = offline
+		return nil
+	}
+}
+
+// WithHashicorpRetries sets the number of times an instance of
+// HashicorpClient retries a request after a connection error or transient
+// server error, and the delay before the first retry, which doubles for
+// each subsequent retry.
+func WithHashicorpRetries(maxRetries int, baseDelay time.Duration) hashicorpClientOption {
+	return func(c *HashicorpClient) error {
+		if maxRetries < 0 || baseDelay < 0 {
+			return errors.New("the number of retries and delay cannot be negative")
+		}
+		c.retryPolicy.maxRetries = maxRetries
+		c.retryPolicy.baseDelay = baseDelay
+		return nil
+	}
+}
+
+// WithHashicorpProgress sets a function for an instance of HashicorpClient,
+// which receives progress events while builds are downloaded.
+func WithHashicorpProgress(progress ProgressFunc) hashicorpClientOption {
+	return func(c *HashicorpClient) error {
+		c.progress = progress
+		return nil
+	}
+}
+
 func NewHashicorpClient(options ...hashicorpClientOption) (*HashicorpClient, error) {
 	c := &HashicorpClient{
-		apiHost:    "https://api.releases.hashicorp.com",
-		httpClient: &defaultHTTPClient,
+		apiHost:     "https://api.releases.hashicorp.com",
+		httpClient:  &defaultHTTPClient,
+		retryPolicy: defaultRetryPolicy,
 	}
 	for _, o := range options {
 		err := o(c)
@@ -75,6 +132,22 @@ func NewHashicorpClient(options ...hashicorpClientOption) (*HashicorpClient, err
 	return c, nil
 }
 
+// downloader returns a downloader using the options of the HashicorpClient.
+func (c HashicorpClient) downloader() downloader {
+	return downloader{
+		do:         c.do,
+		cache:      c.downloadCache,
+		offline:    c.offline,
+		progress:   c.progress,
+		maxResumes: defaultMaxResumes,
+	}
+}
+
+// do sends the HTTP request with retries.
+func (c HashicorpClient) do(req *http.Request) (*http.Response, error) {
+	return c.retryPolicy.do(c.httpClient, req)
+}
+
 type hashicorpBuild struct {
 	Arch string `json:"arch"`
 	OS   string `json:"os"`
@@ -145,25 +218,25 @@ func (h HashicorpProduct) GetName() string {
 	return h.name
 }
 
-func (h *HashicorpProduct) hashicorpAPIRequest(method, URI string) (*http.Response, error) {
+func (h *HashicorpProduct) hashicorpAPIRequest(ctx context.Context, method, URI string) (*http.Response, error) {
 	if !strings.HasPrefix(URI, "/") {
 		URI = "/" + URI
 	}
 	URL := h.client.apiHost + URI
-	req, err := http.NewRequest(method, URL, nil)
+	req, err := http.NewRequestWithContext(ctx, method, URL, nil)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := h.client.httpClient.Do(req)
+	resp, err := doAPIRequest(h.client.do, req, h.client.apiCache, h.client.offline)
 	if err != nil {
 		return nil, err
 	}
 	return resp, nil
 }
 
-func (h HashicorpProduct) Exists() (bool, error) {
+func (h HashicorpProduct) Exists(ctx context.Context) (bool, error) {
 	URI := "/v1/products"
-	resp, err := h.hashicorpAPIRequest(http.MethodGet, URI)
+	resp, err := h.hashicorpAPIRequest(ctx, http.MethodGet, URI)
 	if err != nil {
 		return false, err
 	}
@@ -187,13 +260,13 @@ func (h HashicorpProduct) Exists() (bool, error) {
 	return false, nil
 }
 
-func (h *HashicorpProduct) fetchReleases() (hashicorpReleases, error) {
+func (h *HashicorpProduct) fetchReleases(ctx context.Context) (hashicorpReleases, error) {
 	URI := "/v1/releases/" + h.name + "?limit=20"
 	if h.oldestSeenReleaseTimestamp != "" {
 		URI += "&after=" + h.oldestSeenReleaseTimestamp
 	}
 	debugLog.Printf("fetching Hashicorp %s releases with URI %s", h.name, URI)
-	resp, err := h.hashicorpAPIRequest(http.MethodGet, URI)
+	resp, err := h.hashicorpAPIRequest(ctx, http.MethodGet, URI)
 	if err != nil {
 		return hashicorpReleases{}, err
 	}
@@ -223,9 +296,9 @@ func (h *HashicorpProduct) fetchReleases() (hashicorpReleases, error) {
 // if an empty string or `latest` is specified.
 // IF the explicit version is not found,
 // HashicorpProduct.releaseForPartialVersion is called.
-func (h HashicorpProduct) releaseForVersion(version string) (release hashicorpRelease, found bool, err error) {
+func (h HashicorpProduct) releaseForVersion(ctx context.Context, version string) (release hashicorpRelease, found bool, err error) {
 	debugLog.Printf("getting Hashicorp %s release for version %q", h.name, version)
-	ok, err := h.Exists()
+	ok, err := h.Exists(ctx)
 	if err != nil {
 		return hashicorpRelease{}, false, err
 	}
@@ -236,13 +309,13 @@ func (h HashicorpProduct) releaseForVersion(version string) (release hashicorpRe
 		version = "latest"
 	}
 	URI := "/v1/releases/" + h.name + "/" + version
-	resp, err := h.hashicorpAPIRequest(http.MethodGet, URI)
+	resp, err := h.hashicorpAPIRequest(ctx, http.MethodGet, URI)
 	if err != nil {
 		return hashicorpRelease{}, false, err
 	}
 	if resp.StatusCode == http.StatusNotFound {
 		debugLog.Printf("Hashicorp %s version %q not found", h.name, version)
-		return h.releaseForPartialVersion(version)
+		return h.releaseForPartialVersion(ctx, version)
 	}
 	if resp.StatusCode != http.StatusOK {
 		return hashicorpRelease{}, false, fmt.Errorf("HTTP %d for %s", resp.StatusCode, URI)
@@ -263,13 +336,13 @@ func (h HashicorpProduct) releaseForVersion(version string) (release hashicorpRe
 // releaseForPartialVersion fetches Hashicorp releases, and
 // wraps hashicorpReleases.ForPartialVersion until the latest partial version
 // is matched, or there are no more releases available.
-func (h HashicorpProduct) releaseForPartialVersion(version string) (release hashicorpRelease, found bool, err error) {
+func (h HashicorpProduct) releaseForPartialVersion(ctx context.Context, version string) (release hashicorpRelease, found bool, err error) {
 	debugLog.Printf("finding Hashicorp %s release matching partial version %q", h.name, version)
 	if version == "" || strings.EqualFold(version, "latest") {
-		return h.releaseForVersion("latest")
+		return h.releaseForVersion(ctx, "latest")
 	}
 	var releases hashicorpReleases
-	releases, err = h.fetchReleases()
+	releases, err = h.fetchReleases(ctx)
 	if err != nil {
 		return hashicorpRelease{}, false, err
 	}
@@ -282,7 +355,7 @@ func (h HashicorpProduct) releaseForPartialVersion(version string) (release hash
 		if found {
 			return release, true, nil
 		}
-		releases, err = h.fetchReleases()
+		releases, err = h.fetchReleases(ctx)
 		if err != nil {
 			return hashicorpRelease{}, false, err
 		}
@@ -291,35 +364,14 @@ func (h HashicorpProduct) releaseForPartialVersion(version string) (release hash
 	return hashicorpRelease{}, false, nil
 }
 
-func (h HashicorpProduct) Download(build hashicorpBuild) (filePath string, err error) {
+func (h HashicorpProduct) Download(ctx context.Context, build hashicorpBuild) (filePath string, err error) {
 	debugLog.Printf("downloading Hashicorp build from %s", build.URL)
-	req, err := http.NewRequest(http.MethodGet, build.URL, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, build.URL, nil)
 	if err != nil {
 		return "", err
 	}
 	req.Header.Add("Accept", "application/octet-stream")
-	resp, err := h.client.httpClient.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("HTTP %d for %s", resp.StatusCode, build.URL)
-	}
-	tempDir, err := os.MkdirTemp(os.TempDir(), callMeProgName+"-")
-	if err != nil {
-		return "", err
-	}
-	filePath = fmt.Sprintf("%s/%s", tempDir, filepath.Base(build.URL))
-	f, err := os.Create(filePath)
-	if err != nil {
-		return "", err
-	}
-	defer f.Close()
-	if _, err := io.Copy(f, resp.Body); err != nil {
-		return "", err
-	}
-	return filePath, nil
+	return h.client.downloader().download(req, filepath.Base(build.URL))
 }
 
 // DownloadReleaseForVersion downloads the specified version of the Hashicorp
@@ -327,8 +379,8 @@ func (h HashicorpProduct) Download(build hashicorpBuild) (filePath string, err e
 // was downloaded.
 // A version of `latest` or an empty string will download the latest
 // non-pre-release version.
-func (h HashicorpProduct) DownloadReleaseForVersion(version string) (binaryPath, matchedVersion string, err error) {
-	release, ok, err := h.releaseForVersion(version)
+func (h HashicorpProduct) DownloadReleaseForVersion(ctx context.Context, version string) (binaryPath, matchedVersion string, err error) {
+	release, ok, err := h.releaseForVersion(ctx, version)
 	if err != nil {
 		return "", "", err
 	}
@@ -340,7 +392,7 @@ func (h HashicorpProduct) DownloadReleaseForVersion(version string) (binaryPath,
 	if !ok {
 		return "", "", fmt.Errorf("no builds of %s version %s match OS %q and architecture %q", h.name, version, runtime.GOOS, runtime.GOARCH)
 	}
-	downloadedFile, err := h.Download(build)
+	downloadedFile, err := h.Download(ctx, build)
 	if err != nil {
 		return "", "", err
 	}
@@ -1,6 +1,7 @@
 package jkl
 
 import (
+	"context"
 	"fmt"
 )
 
@@ -18,19 +19,19 @@ URL of the form: https://get.helm.sh/helm-v{version}-${GOOS}-{GOARCH}.tar.gz
 // The toolSpec may also be updated with the
 // version of Helm that was downloaded, in cases where a partial or
 // "latest" version is specified.
-func HelmDownload(TS *ToolSpec) error {
-	g, err := NewGithubRepo("helm/helm")
+func HelmDownload(ctx context.Context, TS *ToolSpec, clientOptions ...githubClientOption) error {
+	g, err := NewGithubRepo("helm/helm", clientOptions...)
 	if err != nil {
 		return err
 	}
-	tag, ok, err := g.findTagForVersion(TS.version)
+	tag, ok, err := g.findTagForVersion(ctx, TS.version)
 	if err != nil {
 		return err
 	}
 	if !ok {
 		return fmt.Errorf("no github tag found matching helm version %q", TS.version)
 	}
-	binaryPath, err := g.DownloadHelmBinaryForTag(tag)
+	binaryPath, err := g.DownloadHelmBinaryForTag(ctx, tag)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,173 @@
+package jkl
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultHTTPTimeout is how long an HTTP request, including reading its
+// response, can take unless a timeout is configured for its host.
+const DefaultHTTPTimeout = 30 * time.Second
+
+// NewHTTPClient returns an http.Client which uses the specified proxy, CA
+// certificates, timeouts, and mirrors. Without a configured proxy, the
+// HTTPS_PROXY, HTTP_PROXY, and NO_PROXY environment variables are used.
+func NewHTTPClient(config HTTPConfig, mirrors []MirrorConfig) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.Proxy != "" {
+		proxyURL, err := url.Parse(config.Proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", config.Proxy)
+		}
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			if hostMatchesOneOf(req.URL.Hostname(), config.NoProxy) {
+				return nil, nil
+			}
+			return proxyURL, nil
+		}
+	}
+	if len(config.CACertificates) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			debugLog.Printf("using only the configured CA certificates, as the system certificates cannot be loaded: %v", err)
+			pool = x509.NewCertPool()
+		}
+		for _, fileName := range config.CACertificates {
+			PEM, err := os.ReadFile(fileName)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read CA certificates: %v", err)
+			}
+			if !pool.AppendCertsFromPEM(PEM) {
+				return nil, fmt.Errorf("no PEM-encoded CA certificates found in %s", fileName)
+			}
+			debugLog.Printf("added CA certificates from %s", fileName)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	for _, mirror := range mirrors {
+		if mirror.From == "" || mirror.To == "" {
+			return nil, errors.New("mirrors must specify both a URL prefix to match (from) and its replacement (to)")
+		}
+	}
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = DefaultHTTPTimeout
+	}
+	hostTimeouts := make(map[string]time.Duration, len(config.HostTimeouts))
+	for host, hostTimeout := range config.HostTimeouts {
+		hostTimeouts[strings.ToLower(host)] = hostTimeout
+	}
+	return &http.Client{
+		Transport: &configuredTransport{
+			base:         transport,
+			timeout:      timeout,
+			hostTimeouts: hostTimeouts,
+			mirrors:      mirrors,
+		},
+	}, nil
+}
+
+// hostMatchesOneOf returns true if the host is one of the specified domains,
+// or a sub-domain of one.
+func hostMatchesOneOf(host string, domains []string) bool {
+	host = strings.ToLower(host)
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// configuredTransport is an http.RoundTripper which rewrites request URLs
+// to mirrors, and applies a timeout per host. Because redirects are sent
+// through the transport, a redirect to a mirrored URL is also rewritten.
+type configuredTransport struct {
+	base         http.RoundTripper
+	timeout      time.Duration
+	hostTimeouts map[string]time.Duration
+	mirrors      []MirrorConfig
+}
+
+// mirrorURL returns the URL rewritten by the first mirror whose prefix it
+// matches.
+func (t configuredTransport) mirrorURL(URL *url.URL) (*url.URL, bool) {
+	for _, mirror := range t.mirrors {
+		if !strings.HasPrefix(URL.String(), mirror.From) {
+			continue
+		}
+		mirroredURL, err := url.Parse(mirror.To + strings.TrimPrefix(URL.String(), mirror.From))
+		if err != nil {
+			debugLog.Printf("ignoring the mirror for %s, as the rewritten URL is invalid: %v", mirror.From, err)
+			continue
+		}
+		return mirroredURL, true
+	}
+	return nil, false
+}
+
+// isMirrored returns true if the HTTP client rewrites the URL to a mirror.
+func isMirrored(hc *http.Client, URL string) bool {
+	if hc == nil {
+		return false
+	}
+	t, ok := hc.Transport.(*configuredTransport)
+	if !ok {
+		return false
+	}
+	u, err := url.Parse(URL)
+	if err != nil {
+		return false
+	}
+	_, ok = t.mirrorURL(u)
+	return ok
+}
+
+func (t configuredTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if mirroredURL, ok := t.mirrorURL(req.URL); ok {
+		debugLog.Printf("using mirror URL %s for %s", mirroredURL, req.URL)
+		originalHost := req.URL.Host
+		req = req.Clone(req.Context())
+		req.URL = mirroredURL
+		req.Host = ""
+		if !strings.EqualFold(mirroredURL.Host, originalHost) {
+			// Credentials for the original host are not sent to the mirror.
+			req.Header.Del("Authorization")
+		}
+	}
+	timeout, ok := t.hostTimeouts[strings.ToLower(req.URL.Hostname())]
+	if !ok {
+		timeout = t.timeout
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// The timeout applies until the response body is closed.
+	resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnCloseBody cancels a context when the response body is closed.
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
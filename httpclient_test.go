@@ -0,0 +1,141 @@
+package jkl_test
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ivanfetch/jkl"
+)
+
+// httpGet uses the http.Client to GET the URL, returning the response body.
+func httpGet(hc *http.Client, URL string, headers map[string]string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, URL, nil)
+	if err != nil {
+		return "", err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP %d for %s", resp.StatusCode, URL)
+	}
+	return string(body), nil
+}
+
+func TestHTTPClientMirrorsWithCACertificates(t *testing.T) {
+	t.Parallel()
+	mirror := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s authorization=%q", r.URL.Path, r.Header.Get("Authorization"))
+	}))
+	t.Cleanup(mirror.Close)
+	CAFile := filepath.Join(t.TempDir(), "ca.pem")
+	err := os.WriteFile(CAFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: mirror.Certificate().Raw}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mirrors := []jkl.MirrorConfig{
+		{
+			From: "https://releases.example.invalid/",
+			To:   mirror.URL + "/artifactory/releases/",
+		},
+	}
+	hc, err := jkl.NewHTTPClient(jkl.HTTPConfig{CACertificates: []string{CAFile}}, mirrors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := httpGet(hc, "https://releases.example.invalid/tool/1.0.0/tool.zip", map[string]string{"Authorization": "token secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `/artifactory/releases/tool/1.0.0/tool.zip authorization=""`
+	if got != want {
+		t.Fatalf("want the mirror to respond %q, got %q", want, got)
+	}
+	withoutCA, err := jkl.NewHTTPClient(jkl.HTTPConfig{}, mirrors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = httpGet(withoutCA, "https://releases.example.invalid/tool/1.0.0/tool.zip", nil)
+	if err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Fatalf("want a certificate error without the mirror CA certificate, got: %v", err)
+	}
+}
+
+func TestHTTPClientProxy(t *testing.T) {
+	t.Parallel()
+	var proxiedRequests atomic.Int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxiedRequests.Add(1)
+		fmt.Fprintf(w, "proxied %s", r.URL)
+	}))
+	t.Cleanup(proxy.Close)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "direct")
+	}))
+	t.Cleanup(server.Close)
+	hc, err := jkl.NewHTTPClient(jkl.HTTPConfig{
+		Proxy:   proxy.URL,
+		NoProxy: []string{"localhost"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := httpGet(hc, "http://tools.example.invalid/tool", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "proxied http://tools.example.invalid/tool" {
+		t.Fatalf("want the request to be sent to the proxy, got %q", got)
+	}
+	got, err = httpGet(hc, strings.Replace(server.URL, "127.0.0.1", "localhost", 1), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "direct" || proxiedRequests.Load() != 1 {
+		t.Fatalf("want a request to a no_proxy host to not use the proxy, got %q and %d proxied requests", got, proxiedRequests.Load())
+	}
+}
+
+func TestHTTPClientHostTimeouts(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		fmt.Fprint(w, "slow")
+	}))
+	t.Cleanup(server.Close)
+	hc, err := jkl.NewHTTPClient(jkl.HTTPConfig{
+		HostTimeouts: map[string]time.Duration{"127.0.0.1": 50 * time.Millisecond},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = httpGet(hc, server.URL, nil)
+	if err == nil {
+		t.Fatal("want an error when the host timeout is exceeded")
+	}
+	got, err := httpGet(hc, strings.Replace(server.URL, "127.0.0.1", "localhost", 1), nil)
+	if err != nil {
+		t.Fatalf("want the default timeout for other hosts: %v", err)
+	}
+	if got != "slow" {
+		t.Fatalf("want response %q, got %q", "slow", got)
+	}
+}
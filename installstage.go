@@ -0,0 +1,128 @@
+package jkl
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// installStage represents a version directory of a managedTool which is
+// populated beside its final location, then renamed into place. This
+// avoids an interrupted installation leaving a partially installed version.
+// The staging directory is hidden, so it is not listed as an installed
+// version.
+type installStage struct {
+	stageDir   string // populated before being renamed to versionDir
+	versionDir string // the final location of the installed version
+	backupDir  string // a previous installation of this version, restored upon rollback
+	committed  bool
+}
+
+// stageVersion returns an installStage for the specified version of the
+// managedTool, creating its staging directory.
+func (t managedTool) stageVersion(version string) (*installStage, error) {
+	if version == "" || version == "." || version == ".." || filepath.Base(version) != version {
+		return nil, fmt.Errorf("invalid version %q for %s", version, t.name)
+	}
+	toolDir := filepath.Join(t.jkl.installsDir, t.name)
+	err := os.MkdirAll(toolDir, 0700)
+	if err != nil {
+		return nil, err
+	}
+	stageDir, err := os.MkdirTemp(toolDir, fmt.Sprintf(".%s.staging-", version))
+	if err != nil {
+		return nil, err
+	}
+	debugLog.Printf("staging version %s of %s in %q", version, t.name, stageDir)
+	return &installStage{
+		stageDir:   stageDir,
+		versionDir: filepath.Join(toolDir, version),
+	}, nil
+}
+
+// addExecutable copies the specified file into the staging directory,
+// naming it after the tool.
+func (s *installStage) addExecutable(sourceFilePath, toolName string) error {
+	return CopyExecutableToCreatedDir(sourceFilePath, filepath.Join(s.stageDir, toolName))
+}
+
+// commit renames the staging directory to the final version directory. An
+// existing installation of the same version is set aside, to be restored by
+// rollback() or removed by finish().
+func (s *installStage) commit() error {
+	err := syncDir(s.stageDir)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stat(s.versionDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		s.backupDir = s.stageDir + ".previous"
+		debugLog.Printf("moving existing installation %q to %q", s.versionDir, s.backupDir)
+		err = os.Rename(s.versionDir, s.backupDir)
+		if err != nil {
+			s.backupDir = ""
+			return err
+		}
+	}
+	debugLog.Printf("renaming staged installation %q to %q", s.stageDir, s.versionDir)
+	err = os.Rename(s.stageDir, s.versionDir)
+	if err != nil {
+		if s.backupDir != "" {
+			restoreErr := os.Rename(s.backupDir, s.versionDir)
+			if restoreErr != nil {
+				return fmt.Errorf("%v, and while restoring the previous installation from %s: %v", err, s.backupDir, restoreErr)
+			}
+			s.backupDir = ""
+		}
+		return err
+	}
+	s.committed = true
+	return syncDir(filepath.Dir(s.versionDir))
+}
+
+// rollback removes a committed version directory, restoring any previous
+// installation of the same version.
+func (s *installStage) rollback() error {
+	if !s.committed {
+		return nil
+	}
+	debugLog.Printf("rolling back installation %q", s.versionDir)
+	err := os.RemoveAll(s.versionDir)
+	if err != nil {
+		return err
+	}
+	s.committed = false
+	if s.backupDir != "" {
+		debugLog.Printf("restoring previous installation %q", s.backupDir)
+		err = os.Rename(s.backupDir, s.versionDir)
+		if err != nil {
+			return err
+		}
+		s.backupDir = ""
+	}
+	return nil
+}
+
+// finish removes the staging directory if the installation was not
+// committed, otherwise any previous installation that was set aside.
+func (s *installStage) finish() {
+	if !s.committed {
+		debugLog.Printf("removing staging directory %q", s.stageDir)
+		err := os.RemoveAll(s.stageDir)
+		if err != nil {
+			debugLog.Printf("cannot remove staging directory %q: %v", s.stageDir, err)
+		}
+	}
+	if s.backupDir != "" {
+		debugLog.Printf("removing previous installation %q", s.backupDir)
+		err := os.RemoveAll(s.backupDir)
+		if err != nil {
+			debugLog.Printf("cannot remove previous installation %q: %v", s.backupDir, err)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package jkl
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -9,7 +10,9 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	homedir "github.com/mitchellh/go-homedir"
@@ -17,17 +20,37 @@ import (
 
 var debugLog *log.Logger = log.New(io.Discard, "", 0)
 
-var defaultHTTPClient http.Client = http.Client{Timeout: time.Second * 30}
+var defaultHTTPClient http.Client = http.Client{Timeout: DefaultHTTPTimeout}
 
 const (
-	callMeProgName = "jkl"
+	callMeProgName            = "jkl"
+	defaultInstallsDir        = "~/.jkl/installs"
+	defaultShimsDir           = "~/.jkl/bin"
+	defaultGlobalVersionsFile = "~/.jkl/tool-versions"
+	defaultLocksDir           = "~/.jkl/locks"
 )
 
 // JKL holds configuration.
 type JKL struct {
-	installsDir string // where downloaded tools are installed
-	shimsDir    string // where shim symlinks are created
-	executable  string // path to the jkl binary
+	installsDir         string        // where downloaded tools are installed
+	shimsDir            string        // where shim symlinks are created
+	executable          string        // path to the jkl binary
+	locksDir            string        // where lock files coordinate concurrent jkl processes
+	cacheDir            string        // where downloads are cached
+	globalVersionsFile  string        // versions used when no other version is configured, in the ASDF .tool-versions format
+	offline             bool          // only use cached API responses and downloads
+	autoInstall         bool          // shims install the version they would run if it is not installed
+	repointShims        bool          // installs repoint shims which point to a different jkl binary
+	apiCacheTTL         time.Duration // how long API responses are used before being revalidated
+	configFile          string        // the jkl configuration file
+	config              Config        // from the jkl configuration file
+	configErr           error         // why the configuration file could not be loaded
+	invalidConfigOK     bool          // NewJKL succeeds with a default config when the configuration file cannot be loaded
+	httpClient          *http.Client  // configured by the HTTP and mirrors settings of config
+	downloadProgress    ProgressFunc  // optional, reports download progress
+	lockTimeout         time.Duration
+	extractOptions      []ExtractOption      // limits used when extracting downloaded archives
+	githubClientOptions []githubClientOption // used when installing Github releases
 }
 
 func EnableDebugOutput() {
@@ -71,6 +94,167 @@ func WithShimsDir(d string) JKLOption {
 	}
 }
 
+// WithLocksDir sets the corresponding field in a JKL type.
+func WithLocksDir(d string) JKLOption {
+	return func(j *JKL) error {
+		if d == "" {
+			return errors.New("the locks directory cannot be empty")
+		}
+		expandedD, err := homedir.Expand(d)
+		if err != nil {
+			return err
+		}
+		j.locksDir = expandedD
+		return nil
+	}
+}
+
+// WithCacheDir sets the corresponding field in a JKL type.
+func WithCacheDir(d string) JKLOption {
+	return func(j *JKL) error {
+		if d == "" {
+			return errors.New("the cache directory cannot be empty")
+		}
+		expandedD, err := homedir.Expand(d)
+		if err != nil {
+			return err
+		}
+		j.cacheDir = expandedD
+		return nil
+	}
+}
+
+// WithGlobalVersionsFile sets the file where global tool versions are stored,
+// instead of the default ~/.jkl/tool-versions.
+func WithGlobalVersionsFile(f string) JKLOption {
+	return func(j *JKL) error {
+		if f == "" {
+			return errors.New("the global versions file cannot be empty")
+		}
+		expandedF, err := homedir.Expand(f)
+		if err != nil {
+			return err
+		}
+		j.globalVersionsFile = expandedF
+		return nil
+	}
+}
+
+// WithConfigFile reads the specified jkl configuration file, instead of the
+// default ~/.jkl/config.yaml.
+func WithConfigFile(f string) JKLOption {
+	return func(j *JKL) error {
+		if f == "" {
+			return errors.New("the configuration file cannot be empty")
+		}
+		expandedF, err := homedir.Expand(f)
+		if err != nil {
+			return err
+		}
+		j.configFile = expandedF
+		// NewJKL returns the error unless WithInvalidConfigAllowed is used.
+		j.config, j.configErr = LoadConfig(expandedF)
+		if j.configErr != nil {
+			return nil
+		}
+		if j.config.AutoInstall {
+			j.autoInstall = true
+		}
+		if j.config.RepointShims {
+			j.repointShims = true
+		}
+		return nil
+	}
+}
+
+// WithInvalidConfigAllowed sets whether NewJKL succeeds when the
+// configuration file cannot be loaded, using the default configuration
+// instead. The error is then reported by Doctor.
+func WithInvalidConfigAllowed(allowed bool) JKLOption {
+	return func(j *JKL) error {
+		j.invalidConfigOK = allowed
+		return nil
+	}
+}
+
+// WithDownloadProgress sets a function which receives progress events while
+// tools are downloaded.
+func WithDownloadProgress(progress ProgressFunc) JKLOption {
+	return func(j *JKL) error {
+		j.downloadProgress = progress
+		return nil
+	}
+}
+
+// WithOfflineMode sets whether a JKL type is offline, resolving versions and
+// downloading tools only from its cache.
+func WithOfflineMode(offline bool) JKLOption {
+	return func(j *JKL) error {
+		j.offline = offline
+		return nil
+	}
+}
+
+// WithAutoInstall sets whether shims install the version of a tool they
+// would run, when that version is not installed.
+func WithAutoInstall(autoInstall bool) JKLOption {
+	return func(j *JKL) error {
+		j.autoInstall = autoInstall
+		return nil
+	}
+}
+
+// WithRepointShims sets whether installing a tool repoints its existing shim,
+// when the shim points to a different jkl binary.
+func WithRepointShims(repoint bool) JKLOption {
+	return func(j *JKL) error {
+		j.repointShims = repoint
+		return nil
+	}
+}
+
+// WithAPICacheTTL sets how long cached API responses, such as release
+// listings, are used before asking the API whether they have changed.
+func WithAPICacheTTL(t time.Duration) JKLOption {
+	return func(j *JKL) error {
+		if t < 0 {
+			return errors.New("the API cache TTL cannot be negative")
+		}
+		j.apiCacheTTL = t
+		return nil
+	}
+}
+
+// WithLockTimeout sets how long to wait for another jkl process to finish
+// modifying a tool, shims, or the jkl binary.
+func WithLockTimeout(t time.Duration) JKLOption {
+	return func(j *JKL) error {
+		if t < 0 {
+			return errors.New("the lock timeout cannot be negative")
+		}
+		j.lockTimeout = t
+		return nil
+	}
+}
+
+// WithGithubClientOptions sets options for the Github client used when
+// installing Github releases, such as WithAPIHost().
+func WithGithubClientOptions(options ...githubClientOption) JKLOption {
+	return func(j *JKL) error {
+		j.githubClientOptions = append(j.githubClientOptions, options...)
+		return nil
+	}
+}
+
+// WithExtractOptions sets options used by ExtractFile() when extracting
+// downloaded archives, such as WithMaxExtractedBytes().
+func WithExtractOptions(options ...ExtractOption) JKLOption {
+	return func(j *JKL) error {
+		j.extractOptions = append(j.extractOptions, options...)
+		return nil
+	}
+}
+
 // NewJKL constructs a new JKL instance, accepting optional parameters via With*()
 // functional options.
 func NewJKL(options ...JKLOption) (*JKL, error) {
@@ -79,25 +263,96 @@ func NewJKL(options ...JKLOption) (*JKL, error) {
 		return nil, fmt.Errorf("cannot get executable to determine its parent directory: %v", err)
 	}
 	j := &JKL{
-		executable: executable,
+		executable:  executable,
+		lockTimeout: 2 * time.Minute,
+		offline:     os.Getenv("JKL_OFFLINE") != "",
+		autoInstall: os.Getenv("JKL_AUTO_INSTALL") != "",
+		apiCacheTTL: DefaultAPICacheTTL,
+	}
+	if TTL := os.Getenv("JKL_API_CACHE_TTL"); TTL != "" {
+		parsedTTL, err := time.ParseDuration(TTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JKL_API_CACHE_TTL: %v", err)
+		}
+		err = WithAPICacheTTL(parsedTTL)(j)
+		if err != nil {
+			return nil, err
+		}
 	}
 	// Use functional options to set default values.
-	setDefaultInstallsDir := WithInstallsDir("~/.jkl/installs")
+	setDefaultInstallsDir := WithInstallsDir(defaultInstallsDir)
 	err = setDefaultInstallsDir(j)
 	if err != nil {
 		return nil, err
 	}
-	setDefaultShimsDir := WithShimsDir("~/.jkl/bin")
+	setDefaultShimsDir := WithShimsDir(defaultShimsDir)
 	err = setDefaultShimsDir(j)
 	if err != nil {
 		return nil, err
 	}
+	setDefaultLocksDir := WithLocksDir(defaultLocksDir)
+	err = setDefaultLocksDir(j)
+	if err != nil {
+		return nil, err
+	}
+	defaultCacheDir := "~/.jkl/cache"
+	if XDGCacheHome := os.Getenv("XDG_CACHE_HOME"); XDGCacheHome != "" {
+		defaultCacheDir = filepath.Join(XDGCacheHome, callMeProgName)
+	}
+	setDefaultCacheDir := WithCacheDir(defaultCacheDir)
+	err = setDefaultCacheDir(j)
+	if err != nil {
+		return nil, err
+	}
+	setDefaultGlobalVersionsFile := WithGlobalVersionsFile(defaultGlobalVersionsFile)
+	err = setDefaultGlobalVersionsFile(j)
+	if err != nil {
+		return nil, err
+	}
+	configFile := DefaultConfigFile
+	if f := os.Getenv("JKL_CONFIG"); f != "" {
+		configFile = f
+	}
+	setDefaultConfigFile := WithConfigFile(configFile)
+	err = setDefaultConfigFile(j)
+	if err != nil {
+		return nil, err
+	}
 	for _, option := range options {
 		err := option(j)
 		if err != nil {
 			return nil, err
 		}
 	}
+	if j.configErr != nil && !j.invalidConfigOK {
+		return nil, j.configErr
+	}
+	// Options set by WithExtractOptions take precedence over the
+	// configuration file.
+	configExtractOptions, err := j.config.Extract.extractOptions()
+	if err != nil {
+		return nil, err
+	}
+	j.extractOptions = append(configExtractOptions, j.extractOptions...)
+	j.httpClient, err = NewHTTPClient(j.config.HTTP, j.config.Mirrors)
+	if err != nil {
+		return nil, fmt.Errorf("while configuring HTTP: %v", err)
+	}
+	return j, nil
+}
+
+// newShimJKL returns a JKL with only the directories needed to run a shim.
+// This skips reading the configuration file and setting up HTTP, which
+// NewJKL does for jkl commands, and which would otherwise add latency to
+// every run of a tool via its shim.
+func newShimJKL() (*JKL, error) {
+	j := &JKL{}
+	for _, option := range []JKLOption{WithInstallsDir(defaultInstallsDir), WithShimsDir(defaultShimsDir), WithLocksDir(defaultLocksDir), WithGlobalVersionsFile(defaultGlobalVersionsFile)} {
+		err := option(j)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return j, nil
 }
 
@@ -106,6 +361,56 @@ func (j JKL) GetExecutable() string {
 	return j.executable
 }
 
+// downloadCache returns the DownloadCache where jkl stores downloads.
+func (j JKL) downloadCache() *DownloadCache {
+	return &DownloadCache{dir: j.cacheDir}
+}
+
+// apiCache returns the APICache where jkl stores API responses.
+func (j JKL) apiCache() *APICache {
+	return &APICache{dir: j.cacheDir, ttl: j.apiCacheTTL}
+}
+
+// githubOptions returns the options for a Github client of the specified
+// source, which use the jkl cache, offline mode, and the configuration for
+// the source's Github host, followed by options set using
+// WithGithubClientOptions.
+func (j JKL) githubOptions(source string) []githubClientOption {
+	options := []githubClientOption{
+		WithHTTPClient(j.httpClient),
+		WithDownloadCache(j.downloadCache()),
+		WithAPICache(j.apiCache()),
+		WithOffline(j.offline),
+		WithProgress(j.downloadProgress),
+	}
+	host, _, err := SplitGithubSource(source)
+	if err == nil && host == "" {
+		host = "github.com"
+	}
+	if hostConfig, ok := j.config.githubHost(host); ok {
+		debugLog.Printf("using the configuration for Github host %s", host)
+		if hostConfig.APIURL != "" {
+			options = append(options, WithAPIHost(strings.TrimSuffix(hostConfig.APIURL, "/")))
+		}
+		if hostConfig.Token != "" {
+			options = append(options, WithToken(hostConfig.Token))
+		}
+	}
+	return append(options, j.githubClientOptions...)
+}
+
+// hashicorpOptions returns the options for Hashicorp clients, which use the
+// jkl cache and offline mode.
+func (j JKL) hashicorpOptions() []hashicorpClientOption {
+	return []hashicorpClientOption{
+		WithHashicorpHTTPClient(j.httpClient),
+		WithHashicorpDownloadCache(j.downloadCache()),
+		WithHashicorpAPICache(j.apiCache()),
+		WithHashicorpOffline(j.offline),
+		WithHashicorpProgress(j.downloadProgress),
+	}
+}
+
 func (j JKL) displayPreFlightCheck(output io.Writer) error {
 	debugLog.Println("starting pre-flight check")
 	shimsDirInPath, err := directoryInPath(j.shimsDir)
@@ -152,72 +457,136 @@ For additional help, run: %[1]s help
 // Install installs the specified tool-specification and creates a shim,
 // returning the version that was installed. The tool-specification represents
 // the tool provider and an optional version.
-func (j JKL) Install(specStr string) (installedVersion string, err error) {
+// If the context is done before the installation is committed, partial work
+// such as temporary files is removed and the context error is returned.
+func (j JKL) Install(ctx context.Context, specStr string) (installedVersion string, err error) {
+	toolSpec, err := j.installToolSpec(ctx, specStr)
+	if err != nil {
+		return "", err
+	}
+	return toolSpec.version, nil
+}
+
+// installToolSpec installs the specified tool-specification like Install,
+// returning the ToolSpec with the name and version of the installed tool.
+func (j JKL) installToolSpec(ctx context.Context, specStr string) (installed ToolSpec, err error) {
 	debugLog.Printf("Installing tool specification %q\n", specStr)
 	toolSpec, err := j.NewToolSpec(specStr)
 	if err != nil {
-		return "", err
+		return ToolSpec{}, err
 	}
 	switch toolSpec.provider {
 	case "github", "gh":
 		var err error
 		switch strings.ToLower(toolSpec.source) {
 		case "helm/helm":
-			err = HelmDownload(&toolSpec)
+			err = HelmDownload(ctx, &toolSpec, j.githubOptions(toolSpec.source)...)
 		default:
-			err = GithubDownload(&toolSpec)
+			err = GithubDownload(ctx, &toolSpec, j.githubOptions(toolSpec.source)...)
 		}
 		if err != nil {
-			return "", err
+			return ToolSpec{}, err
 		}
 	case "hashicorp", "hashi":
-		err := HashicorpDownload(&toolSpec)
+		err := HashicorpDownload(ctx, &toolSpec, j.hashicorpOptions()...)
 		if err != nil {
-			return "", err
+			return ToolSpec{}, err
 		}
 	default:
-		return "", fmt.Errorf("unknown tool provider %q", toolSpec.provider)
+		return ToolSpec{}, fmt.Errorf("unknown tool provider %q", toolSpec.provider)
 	}
-	wasExtracted, err := ExtractFile(toolSpec.downloadPath)
+	// Extract a copy of the download, which may be in the download cache.
+	workDir, err := os.MkdirTemp(os.TempDir(), callMeProgName+"-")
 	if err != nil {
-		return "", err
+		return ToolSpec{}, err
+	}
+	defer func() {
+		debugLog.Printf("removing temporary directory %q", workDir)
+		err := os.RemoveAll(workDir)
+		if err != nil {
+			debugLog.Printf("cannot remove temporary directory %q: %v", workDir, err)
+		}
+	}()
+	err = CopyFile(toolSpec.downloadPath, workDir)
+	if err != nil {
+		return ToolSpec{}, err
+	}
+	workPath := filepath.Join(workDir, filepath.Base(toolSpec.downloadPath))
+	wasExtracted, err := ExtractFile(ctx, workPath, j.extractOptions...)
+	if err != nil {
+		return ToolSpec{}, err
 	}
 	var finalBinary string
 	if wasExtracted {
-		finalBinary = fmt.Sprintf("%s/%s", filepath.Dir(toolSpec.downloadPath), toolSpec.name)
+		finalBinary = filepath.Join(workDir, toolSpec.name)
 		debugLog.Printf("using extracted binary %q for tool %s\n", finalBinary, toolSpec.name)
 	} else {
-		finalBinary = toolSpec.downloadPath
+		finalBinary = workPath
 		debugLog.Printf("using non-extracted binary %q for tool %s\n", finalBinary, toolSpec.name)
 	}
-	installDest := fmt.Sprintf("%s/%s/%s/%s", j.installsDir, toolSpec.name, toolSpec.version, toolSpec.name)
-	err = CopyExecutableToCreatedDir(finalBinary, installDest)
+	err = VerifyExecutable(finalBinary, runtime.GOOS, runtime.GOARCH)
 	if err != nil {
-		return "", err
+		return ToolSpec{}, fmt.Errorf("the file %s chosen from the downloaded asset %s cannot be installed as %s: %v", filepath.Base(finalBinary), filepath.Base(toolSpec.downloadPath), toolSpec.name, err)
 	}
-	err = j.createShim(toolSpec.name)
+	toolLock, err := j.lockTool(ctx, toolSpec.name)
 	if err != nil {
-		return "", err
+		return ToolSpec{}, err
+	}
+	defer toolLock.unlock()
+	tool := j.getManagedTool(toolSpec.name)
+	defer tool.updateVersionIndex() // after the stage is finished
+	stage, err := tool.stageVersion(toolSpec.version)
+	if err != nil {
+		return ToolSpec{}, err
+	}
+	defer stage.finish()
+	err = stage.addExecutable(finalBinary, toolSpec.name)
+	if err != nil {
+		return ToolSpec{}, err
+	}
+	// Once committed, the installation is completed even if the context is done.
+	err = ctx.Err()
+	if err != nil {
+		return ToolSpec{}, err
+	}
+	err = stage.commit()
+	if err != nil {
+		return ToolSpec{}, err
+	}
+	tool.recordSource(toolSpec.provider + ":" + toolSpec.source)
+	err = j.createShim(ctx, toolSpec.name)
+	if err != nil {
+		rollbackErr := stage.rollback()
+		if rollbackErr != nil {
+			return ToolSpec{}, fmt.Errorf("%v, and while rolling back the installation of %s %s: %v", err, toolSpec.name, toolSpec.version, rollbackErr)
+		}
+		return ToolSpec{}, err
 	}
 	debugLog.Printf("Installed %s version %q", toolSpec.name, toolSpec.version)
-	return toolSpec.version, nil
+	return toolSpec, nil
 }
 
 // Uninstall uninsalls the specified managedTool. All versions will be
 // uninstalled unless a version is specified.
-func (j JKL) Uninstall(toolNameAndVersion string) error {
+func (j JKL) Uninstall(ctx context.Context, toolNameAndVersion string) error {
 	toolFields := strings.Split(toolNameAndVersion, ":")
 	toolName := toolFields[0]
 	var toolVersion string
 	if len(toolFields) == 2 {
 		toolVersion = toolFields[1]
 	}
+	toolLock, err := j.lockTool(ctx, toolName)
+	if err != nil {
+		return err
+	}
+	defer toolLock.unlock()
 	tool := j.getManagedTool(toolName)
+	defer tool.updateVersionIndex()
 	if toolVersion == "" {
 		debugLog.Printf("uninstalling all versions of %s", toolName)
-		return tool.uninstallAllVersions()
+		return tool.uninstallAllVersions(ctx)
 	}
-	err := tool.uninstallVersion(toolVersion)
+	err = tool.uninstallVersion(toolVersion)
 	if err != nil {
 		return err
 	}
@@ -226,7 +595,10 @@ func (j JKL) Uninstall(toolNameAndVersion string) error {
 
 // CreateShim creates a symbolic link for the specified tool name, pointing to
 // the JKL binary.
-func (j JKL) createShim(binaryName string) error {
+// A shim which points to a different jkl binary, such as after the jkl binary
+// was moved, is repointed if enabled by WithRepointShims, otherwise an error
+// is returned.
+func (j JKL) createShim(ctx context.Context, binaryName string) error {
 	debugLog.Printf("Assessing shim %s\n", binaryName)
 	_, err := os.Stat(j.shimsDir)
 	if err != nil && !errors.Is(err, fs.ErrNotExist) {
@@ -239,40 +611,13 @@ func (j JKL) createShim(binaryName string) error {
 			return err
 		}
 	}
-	shimPath := filepath.Join(j.shimsDir, binaryName)
-	shimStat, err := os.Lstat(shimPath)
-	if err != nil && !errors.Is(err, fs.ErrNotExist) {
-		return fmt.Errorf("while looking for existing shim %s: %v", shimPath, err)
-	}
-	if errors.Is(err, fs.ErrNotExist) {
-		debugLog.Printf("Creating shim %s -> %s\n", binaryName, j.GetExecutable())
-		err = os.Symlink(j.GetExecutable(), shimPath)
-		if err != nil {
-			return err
-		}
-		return nil
-	}
-	// The shim did not need to be created, verify it is correct.
-	if shimStat.Mode()&fs.ModeSymlink == 0 {
-		return fmt.Errorf("not overwriting existing incorrect shim %s which should be a symlink (%v), but is instead mode %v", shimPath, fs.ModeSymlink, shimStat.Mode())
-	}
-	shimDest, err := filepath.EvalSymlinks(shimPath)
-	if err != nil {
-		return fmt.Errorf("while dereferencing shim symlink %s: %v", shimPath, err)
-	}
-	shimDestStat, err := os.Stat(shimDest)
-	if err != nil {
-		return err
-	}
-	executableStat, err := os.Stat(j.GetExecutable())
+	shimsLock, err := j.lock(ctx, "shims")
 	if err != nil {
 		return err
 	}
-	if os.SameFile(shimDestStat, executableStat) {
-		debugLog.Printf("shim for %s already exists", shimPath)
-		return nil
-	}
-	return fmt.Errorf("shim %s already exists but points to %q instead of %q", shimPath, shimDest, j.GetExecutable())
+	defer shimsLock.unlock()
+	_, err = j.ensureShim(binaryName, j.repointShims)
+	return err
 }
 
 func (j JKL) displayInstalledTools(output io.Writer) error {
@@ -281,11 +626,44 @@ func (j JKL) displayInstalledTools(output io.Writer) error {
 		return err
 	}
 	for _, v := range toolNames {
+		globalVersion, ok, err := j.GlobalVersion(v)
+		if err != nil {
+			return err
+		}
+		if ok {
+			fmt.Fprintf(output, "%s (global version %s)\n", v, globalVersion)
+			continue
+		}
 		fmt.Fprintln(output, v)
 	}
 	return nil
 }
 
+// displayCurrentVersions shows the version of each installed tool that its
+// shim runs, and where that version was specified.
+func (j JKL) displayCurrentVersions(output io.Writer) error {
+	toolNames, err := j.listInstalledTools()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TOOL\tVERSION\tSET BY")
+	for _, toolName := range toolNames {
+		toolVersion, err := j.CurrentVersion(toolName)
+		var noVersionSelected noVersionSelectedError
+		if errors.As(err, &noVersionSelected) {
+			fmt.Fprintf(w, "%s\t-\tnothing, multiple versions are installed (see: %s global --help)\n", toolName, callMeProgName)
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(w, "%s\t-\t%v\n", toolName, err)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", toolName, toolVersion.Version, toolVersion.Source)
+	}
+	return w.Flush()
+}
+
 func (j JKL) displayInstalledVersionsOfTool(output io.Writer, toolName string) error {
 	tool := j.getManagedTool(toolName)
 	toolVersions, ok, err := tool.listInstalledVersions()
@@ -296,7 +674,18 @@ func (j JKL) displayInstalledVersionsOfTool(output io.Writer, toolName string) e
 		fmt.Fprintf(output, "%s is not installed\n", toolName)
 		return nil
 	}
+	globalVersion, ok, err := j.GlobalVersion(toolName)
+	if err != nil {
+		return err
+	}
+	if ok && strings.EqualFold(globalVersion, "latest") {
+		globalVersion = toolVersions[len(toolVersions)-1]
+	}
 	for _, v := range toolVersions {
+		if ok && (v == globalVersion || v == toggleVPrefix(globalVersion)) {
+			fmt.Fprintf(output, "%s (global)\n", v)
+			continue
+		}
 		fmt.Fprintln(output, v)
 	}
 	return nil
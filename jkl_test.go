@@ -1,7 +1,24 @@
 package jkl_test
 
 import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
 
 	"github.com/ivanfetch/jkl"
 )
@@ -13,3 +30,659 @@ func init() {
 		jkl.EnableDebugOutput()
 	}
 }
+
+// runWithIsolatedEnvironment returns the result of run, after clearing
+// environment variables which provide Github tokens or configure jkl, and
+// pointing HOME, TMPDIR, and the gh CLI and netrc files at a temporary
+// directory. This keeps the tokens and configuration of the machine running
+// tests away from test servers, and out of test results.
+// Tests are isolated once for the test binary, as tests which run in
+// parallel cannot use t.Setenv.
+func runWithIsolatedEnvironment(run func() int) int {
+	dir, err := os.MkdirTemp("", "jkltest-")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot create a temporary directory to isolate tests: %v\n", err)
+		return 1
+	}
+	defer os.RemoveAll(dir)
+	for _, envVar := range os.Environ() {
+		name, _, _ := strings.Cut(envVar, "=")
+		if (strings.HasPrefix(name, "JKL_") && name != "JKL_DEBUG") || strings.HasPrefix(name, "_JKL_") {
+			os.Unsetenv(name)
+		}
+	}
+	for _, name := range []string{"GH_TOKEN", "GITHUB_TOKEN", "GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN", "XDG_CONFIG_HOME", "XDG_CACHE_HOME"} {
+		os.Unsetenv(name)
+	}
+	for name, value := range map[string]string{
+		"HOME":          filepath.Join(dir, "home"),
+		"TMPDIR":        filepath.Join(dir, "tmp"),
+		"GH_CONFIG_DIR": filepath.Join(dir, "gh"),
+		"NETRC":         filepath.Join(dir, "netrc"),
+	} {
+		os.Setenv(name, value)
+	}
+	for _, subDir := range []string{"home", "tmp"} {
+		err = os.Mkdir(filepath.Join(dir, subDir), 0700)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot create a directory to isolate tests: %v\n", err)
+			return 1
+		}
+	}
+	return run()
+}
+
+// fakeGithubServer is an httptest.Server which stands in for the Github API.
+type fakeGithubServer struct {
+	*httptest.Server
+	assetDownloads  atomic.Int32 // the number of release assets served
+	mirrorDownloads atomic.Int32 // the number of release assets served under /mirror/
+	tarGzAssets     atomic.Bool  // serve release assets as tar.gz archives, which also contain a README
+	apiRequests     atomic.Int32 // the number of API requests received
+	notModified     atomic.Int32 // the number of API requests answered with HTTP 304
+	authorization   atomic.Value // the Authorization header of the last API request
+}
+
+// newFakeGithubServer returns a fakeGithubServer serving the specified
+// release tags of the Github repository ownerAndRepo. Each release has a
+// single asset, a shell script for the current OS and architecture, named
+// after the repository.
+func newFakeGithubServer(t testing.TB, ownerAndRepo string, tags ...string) *fakeGithubServer {
+	t.Helper()
+	return newFakeGithubServerWithAPIPath(t, "", ownerAndRepo, tags...)
+}
+
+// newFakeGithubServerWithAPIPath returns a fakeGithubServer like
+// newFakeGithubServer, serving the API under the specified path, such as
+// /api/v3 for Github Enterprise Server.
+func newFakeGithubServerWithAPIPath(t testing.TB, APIPath, ownerAndRepo string, tags ...string) *fakeGithubServer {
+	t.Helper()
+	toolName := filepath.Base(ownerAndRepo)
+	mux := http.NewServeMux()
+	server := &fakeGithubServer{Server: httptest.NewServer(mux)}
+	t.Cleanup(server.Close)
+	// writeJSON responds with HTTP 304 when the request has the ETag of
+	// the response, like the Github API.
+	writeJSON := func(w http.ResponseWriter, r *http.Request, v interface{}) {
+		server.apiRequests.Add(1)
+		server.authorization.Store(r.Header.Get("Authorization"))
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Errorf("encoding fake Github API response: %v", err)
+		}
+		ETag := fmt.Sprintf(`"%x"`, sha256.Sum256(b))
+		w.Header().Set("ETag", ETag)
+		if r.Header.Get("If-None-Match") == ETag {
+			server.notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, err = w.Write(b)
+		if err != nil {
+			t.Errorf("writing fake Github API response: %v", err)
+		}
+	}
+	mux.HandleFunc(APIPath+"/repos/"+ownerAndRepo, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, r, map[string]string{"full_name": ownerAndRepo})
+	})
+	mux.HandleFunc(APIPath+"/repos/"+ownerAndRepo+"/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, r, map[string]string{"tag_name": tags[len(tags)-1]})
+	})
+	mux.HandleFunc(APIPath+"/repos/"+ownerAndRepo+"/releases", func(w http.ResponseWriter, r *http.Request) {
+		releases := make([]map[string]string, len(tags))
+		for i, tag := range tags {
+			releases[i] = map[string]string{"name": tag, "tag_name": tag}
+		}
+		writeJSON(w, r, releases)
+	})
+	mux.HandleFunc(APIPath+"/repos/"+ownerAndRepo+"/releases/tags/", func(w http.ResponseWriter, r *http.Request) {
+		tag := filepath.Base(r.URL.Path)
+		assetName := fmt.Sprintf("%s_%s_%s_%s", toolName, strings.TrimPrefix(tag, "v"), runtime.GOOS, runtime.GOARCH)
+		if server.tarGzAssets.Load() {
+			assetName += ".tar.gz"
+		}
+		writeJSON(w, r, map[string][]jkl.GithubAsset{
+			"assets": {
+				{
+					Name:               assetName,
+					URL:                server.URL + "/assets/" + tag,
+					BrowserDownloadURL: "https://github.com/" + ownerAndRepo + "/releases/download/" + tag + "/" + assetName,
+				},
+			},
+		})
+	})
+	// The rate limit is nearly exhausted for unauthenticated requests, and
+	// only the token "valid-token" is accepted.
+	mux.HandleFunc(APIPath+"/rate_limit", func(w http.ResponseWriter, r *http.Request) {
+		remaining := 3
+		switch r.Header.Get("Authorization") {
+		case "":
+		case "token valid-token":
+			remaining = 4000
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, r, map[string]interface{}{
+			"resources": map[string]interface{}{
+				"core": map[string]int{"limit": 5000, "remaining": remaining},
+			},
+		})
+	})
+	mux.HandleFunc("/assets/", func(w http.ResponseWriter, r *http.Request) {
+		server.assetDownloads.Add(1)
+		script := fmt.Sprintf("#!/bin/sh\necho %s %s\n", toolName, filepath.Base(r.URL.Path))
+		if !server.tarGzAssets.Load() {
+			fmt.Fprint(w, script)
+			return
+		}
+		gzipWriter := gzip.NewWriter(w)
+		tarWriter := tar.NewWriter(gzipWriter)
+		for _, member := range []struct{ name, content string }{{toolName, script}, {"README.md", "# " + toolName + "\n"}} {
+			err := tarWriter.WriteHeader(&tar.Header{Name: member.name, Mode: 0755, Size: int64(len(member.content))})
+			if err == nil {
+				_, err = tarWriter.Write([]byte(member.content))
+			}
+			if err != nil {
+				t.Errorf("writing fake release asset: %v", err)
+				return
+			}
+		}
+		tarWriter.Close()
+		gzipWriter.Close()
+	})
+	// A mirror of https://github.com/, serving browser download URLs of
+	// release assets.
+	mux.HandleFunc("/mirror/", func(w http.ResponseWriter, r *http.Request) {
+		server.mirrorDownloads.Add(1)
+		fmt.Fprintf(w, "#!/bin/sh\necho %s %s\n", toolName, filepath.Base(filepath.Dir(r.URL.Path)))
+	})
+	return server
+}
+
+// newTestJKL returns a JKL whose directories are within the specified
+// directory, and which installs Github releases from the specified
+// fakeGithubServer.
+func newTestJKL(t testing.TB, dir string, server *fakeGithubServer, options ...jkl.JKLOption) *jkl.JKL {
+	t.Helper()
+	options = append([]jkl.JKLOption{
+		jkl.WithInstallsDir(filepath.Join(dir, "installs")),
+		jkl.WithShimsDir(filepath.Join(dir, "bin")),
+		jkl.WithLocksDir(filepath.Join(dir, "locks")),
+		jkl.WithCacheDir(filepath.Join(dir, "cache")),
+		jkl.WithConfigFile(filepath.Join(dir, "config.yaml")),
+		jkl.WithGlobalVersionsFile(filepath.Join(dir, "tool-versions")),
+		jkl.WithAPICacheTTL(0),
+		jkl.WithGithubClientOptions(jkl.WithAPIHost(server.URL)),
+	}, options...)
+	j, err := jkl.NewJKL(options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return j
+}
+
+func TestConcurrentInstalls(t *testing.T) {
+	t.Parallel()
+	server := newFakeGithubServer(t, "jkltest/tool", "v1.0.0")
+	tempDir := t.TempDir()
+	var wg sync.WaitGroup
+	installErrs := make(chan error, 5)
+	for i := 0; i < cap(installErrs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := newTestJKL(t, tempDir, server).Install(context.Background(), "github:jkltest/tool")
+			installErrs <- err
+		}()
+	}
+	wg.Wait()
+	close(installErrs)
+	for err := range installErrs {
+		if err != nil {
+			t.Errorf("concurrent install: %v", err)
+		}
+	}
+	_, err := os.Stat(filepath.Join(tempDir, "installs/tool/v1.0.0/tool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	shimStat, err := os.Lstat(filepath.Join(tempDir, "bin/tool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shimStat.Mode()&fs.ModeSymlink == 0 {
+		t.Fatalf("want the shim to be a symlink, got mode %v", shimStat.Mode())
+	}
+	gotDirs, err := os.ReadDir(filepath.Join(tempDir, "installs/tool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gotDirs) != 1 || gotDirs[0].Name() != "v1.0.0" {
+		t.Fatalf("want only the installed version directory, got %v", gotDirs)
+	}
+}
+
+func TestReinstallUsesDownloadCache(t *testing.T) {
+	// Not parallel, to check for temporary directories left behind.
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", filepath.Join(tempDir, "tmp"))
+	err := os.Mkdir(filepath.Join(tempDir, "tmp"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newFakeGithubServer(t, "jkltest/tool", "v1.0.0")
+	j := newTestJKL(t, tempDir, server)
+	_, err = j.Install(context.Background(), "github:jkltest/tool:1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = j.Uninstall(context.Background(), "tool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = j.Install(context.Background(), "github:jkltest/tool:1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := server.assetDownloads.Load(); got != 1 {
+		t.Errorf("want the release asset to be downloaded once, got %d downloads", got)
+	}
+	_, err = os.Stat(filepath.Join(tempDir, "installs/tool/v1.0.0/tool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	leftovers, err := os.ReadDir(filepath.Join(tempDir, "tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leftovers) != 0 {
+		t.Errorf("want no temporary files left after installing, got %v", leftovers)
+	}
+}
+
+func TestCancelledInstallRemovesPartialWork(t *testing.T) {
+	// Not parallel, to check for temporary directories left behind.
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", filepath.Join(tempDir, "tmp"))
+	err := os.Mkdir(filepath.Join(tempDir, "tmp"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newFakeGithubServer(t, "jkltest/tool", "v1.0.0")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// Cancel while the release asset is being downloaded.
+	j := newTestJKL(t, tempDir, server, jkl.WithDownloadProgress(func(jkl.DownloadProgress) {
+		cancel()
+	}))
+	_, err = j.Install(ctx, "github:jkltest/tool:1.0.0")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("want a context.Canceled error, got: %v", err)
+	}
+	for _, dir := range []string{"tmp", "installs/tool", "bin"} {
+		leftovers, err := os.ReadDir(filepath.Join(tempDir, dir))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			t.Fatal(err)
+		}
+		if len(leftovers) != 0 {
+			t.Errorf("want nothing left in %s after a cancelled installation, got %v", dir, leftovers)
+		}
+	}
+}
+
+func TestOfflineInstallUsesCache(t *testing.T) {
+	t.Parallel()
+	server := newFakeGithubServer(t, "jkltest/tool", "v1.0.0", "v1.1.0")
+	tempDir := t.TempDir()
+	_, err := newTestJKL(t, tempDir, server).Install(context.Background(), "github:jkltest/tool:1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Close() // Any network access will now fail
+	j := newTestJKL(t, tempDir, server, jkl.WithOfflineMode(true))
+	err = j.Uninstall(context.Background(), "tool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotVersion, err := j.Install(context.Background(), "github:jkltest/tool:1.0")
+	if err != nil {
+		t.Fatalf("installing a previously downloaded version in offline mode: %v", err)
+	}
+	if gotVersion != "v1.0.0" {
+		t.Fatalf("want version v1.0.0 installed in offline mode, got %q", gotVersion)
+	}
+	testCases := []struct {
+		description string
+		spec        string
+	}{
+		{
+			description: "version that was not downloaded",
+			spec:        "github:jkltest/tool:1.1.0",
+		},
+		{
+			description: "repository that was not fetched",
+			spec:        "github:jkltest/othertool",
+		},
+		{
+			description: "latest version that was not fetched",
+			spec:        "github:jkltest/tool",
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			_, err := j.Install(context.Background(), tc.spec)
+			if err == nil {
+				t.Fatal("an error is expected when installing something that is not cached in offline mode")
+			}
+			if !strings.Contains(err.Error(), "offline mode") {
+				t.Fatalf("want the error to mention offline mode, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestAPIResponsesAreCached(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		description     string
+		TTL             time.Duration
+		wantAPIRequests int32
+		wantNotModified int32
+	}{
+		{
+			description:     "revalidated using conditional requests",
+			TTL:             0,
+			wantAPIRequests: 6, // the repository, releases, and release for the tag, per install
+			wantNotModified: 3,
+		},
+		{
+			description:     "used without requests within the TTL",
+			TTL:             time.Hour,
+			wantAPIRequests: 3, // only for the first install
+			wantNotModified: 0,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			server := newFakeGithubServer(t, "jkltest/tool", "v1.0.0")
+			j := newTestJKL(t, t.TempDir(), server, jkl.WithAPICacheTTL(tc.TTL))
+			for i := 0; i < 2; i++ {
+				_, err := j.Install(context.Background(), "github:jkltest/tool:1.0.0")
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := server.apiRequests.Load(); got != tc.wantAPIRequests {
+				t.Errorf("want %d API requests, got %d", tc.wantAPIRequests, got)
+			}
+			if got := server.notModified.Load(); got != tc.wantNotModified {
+				t.Errorf("want %d not-modified API responses, got %d", tc.wantNotModified, got)
+			}
+		})
+	}
+}
+
+func TestInstallFromGithubEnterpriseServer(t *testing.T) {
+	t.Parallel()
+	server := newFakeGithubServerWithAPIPath(t, "/api/v3", "team/tool", "v1.0.0")
+	tempDir := t.TempDir()
+	configFile := filepath.Join(tempDir, "config.yaml")
+	err := os.WriteFile(configFile, []byte(fmt.Sprintf(`github_hosts:
+  GHE.example.com:
+    api_url: %s/api/v3/
+    token: ghe-token
+`, server.URL)), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := jkl.NewJKL(
+		jkl.WithInstallsDir(filepath.Join(tempDir, "installs")),
+		jkl.WithShimsDir(filepath.Join(tempDir, "bin")),
+		jkl.WithLocksDir(filepath.Join(tempDir, "locks")),
+		jkl.WithCacheDir(filepath.Join(tempDir, "cache")),
+		jkl.WithConfigFile(configFile),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotVersion, err := j.Install(context.Background(), "github:ghe.example.com/team/tool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotVersion != "v1.0.0" {
+		t.Fatalf("want version v1.0.0 installed, got %q", gotVersion)
+	}
+	_, err = os.Stat(filepath.Join(tempDir, "installs/tool/v1.0.0/tool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := server.authorization.Load(); got != "token ghe-token" {
+		t.Fatalf("want the token from the configuration file to be used, got Authorization header %q", got)
+	}
+}
+
+func TestInstallFromGithubMirror(t *testing.T) {
+	t.Parallel()
+	server := newFakeGithubServer(t, "jkltest/tool", "v1.0.0")
+	tempDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(tempDir, "config.yaml"), []byte(fmt.Sprintf(`mirrors:
+  - from: https://github.com/
+    to: %s/mirror/
+`, server.URL)), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := newTestJKL(t, tempDir, server)
+	_, err = j.Install(context.Background(), "github:jkltest/tool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := server.mirrorDownloads.Load(); got != 1 {
+		t.Errorf("want the release asset to be downloaded from the mirror once, got %d downloads", got)
+	}
+	if got := server.assetDownloads.Load(); got != 0 {
+		t.Errorf("want the release asset not to be downloaded using the API, got %d downloads", got)
+	}
+	got, err := os.ReadFile(filepath.Join(tempDir, "installs/tool/v1.0.0/tool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "echo tool v1.0.0"; !strings.Contains(string(got), want) {
+		t.Fatalf("want the installed tool to contain %q, got %q", want, got)
+	}
+}
+
+func TestInstallWithConfiguredExtractLimits(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		description string
+		config      string
+		wantError   string
+	}{
+		{
+			description: "default limits",
+		},
+		{
+			description: "too many files",
+			config:      "extract:\n  max_files: 1\n",
+			wantError:   "more than the maximum of 1 files",
+		},
+		{
+			description: "too many bytes",
+			config:      "extract:\n  max_bytes: 10\n",
+			wantError:   "exceed the maximum of 10 bytes",
+		},
+		{
+			description: "raised limits",
+			config:      "extract:\n  max_bytes: 4294967296\n  max_files: 20000\n",
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			server := newFakeGithubServer(t, "jkltest/tool", "v1.0.0")
+			server.tarGzAssets.Store(true)
+			tempDir := t.TempDir()
+			err := os.WriteFile(filepath.Join(tempDir, "config.yaml"), []byte(tc.config), 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = newTestJKL(t, tempDir, server).Install(context.Background(), "github:jkltest/tool")
+			if tc.wantError == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tc.wantError != "" && (err == nil || !strings.Contains(err.Error(), tc.wantError)) {
+				t.Fatalf("want an error containing %q, got: %v", tc.wantError, err)
+			}
+		})
+	}
+}
+
+func TestAutoInstallToolCommandPath(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		description   string
+		autoInstall   bool
+		unknownSource bool // the recorded source of the tool is removed
+		wantOutput    string
+		wantError     string
+	}{
+		{
+			description: "installs the desired version",
+			autoInstall: true,
+			wantOutput:  "jkl: installing tool v1.1.0 from github:jkltest/tool, as specified by the global version in ",
+		},
+		{
+			description: "disabled",
+			wantError:   "version v1.1.0 of tool is not installed",
+		},
+		{
+			description:   "unknown source",
+			autoInstall:   true,
+			unknownSource: true,
+			wantError:     "the provider of tool is not known",
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			tempDir := t.TempDir()
+			server := newFakeGithubServer(t, "jkltest/tool", "v1.0.0", "v1.1.0")
+			j := newTestJKL(t, tempDir, server, jkl.WithAutoInstall(tc.autoInstall))
+			_, err := j.Install(context.Background(), "github:jkltest/tool:1.0.0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.unknownSource {
+				err = os.Remove(filepath.Join(tempDir, "installs/.tool.source"))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			err = jkl.WriteASDFToolVersion(filepath.Join(tempDir, "tool-versions"), "tool", "v1.1.0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var output strings.Builder
+			got, err := j.AutoInstallToolCommandPath(context.Background(), "tool", &output)
+			if tc.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+					t.Fatalf("want an error containing %q, got %v", tc.wantError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := filepath.Join(tempDir, "installs/tool/v1.1.0/tool")
+			if got != want {
+				t.Fatalf("want path %q, got %q", want, got)
+			}
+			if !strings.HasPrefix(output.String(), tc.wantOutput) {
+				t.Fatalf("want output beginning with %q, got %q", tc.wantOutput, output.String())
+			}
+		})
+	}
+}
+
+func TestReshim(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+	server := newFakeGithubServer(t, "jkltest/tool", "v1.0.0")
+	j := newTestJKL(t, tempDir, server)
+	_, err := j.Install(context.Background(), "github:jkltest/tool:1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	shimsDir := filepath.Join(tempDir, "bin")
+	// A previous location of the jkl binary, which no longer exists.
+	oldExecutable := filepath.Join(tempDir, "old/jkl")
+	toolShim := filepath.Join(shimsDir, "tool")
+	err = os.Remove(toolShim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, shimName := range []string{"tool", "uninstalled"} {
+		err = os.Symlink(oldExecutable, filepath.Join(shimsDir, shimName))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	foreignFile := filepath.Join(shimsDir, "foreign")
+	err = os.WriteFile(foreignFile, []byte("#!/bin/sh\n"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = j.Install(context.Background(), "github:jkltest/tool:1.0.0")
+	if err == nil || !strings.Contains(err.Error(), "jkl reshim") {
+		t.Fatalf("want an error suggesting jkl reshim when installing with a stale shim, got %v", err)
+	}
+	var output strings.Builder
+	err = j.Reshim(context.Background(), &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantOutput := fmt.Sprintf("Repointed shim %s\nRemoved shim %s, as no versions of uninstalled are installed\n", toolShim, filepath.Join(shimsDir, "uninstalled"))
+	if output.String() != wantOutput {
+		t.Fatalf("want output %q, got %q", wantOutput, output.String())
+	}
+	target, err := os.Readlink(toolShim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != j.GetExecutable() {
+		t.Fatalf("want shim %s to point to %q, got %q", toolShim, j.GetExecutable(), target)
+	}
+	_, err = os.Stat(foreignFile)
+	if err != nil {
+		t.Fatalf("want the file %s which was not created by jkl to remain: %v", foreignFile, err)
+	}
+	output.Reset()
+	err = j.Reshim(context.Background(), &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "All shims are up to date\n"; output.String() != want {
+		t.Fatalf("want output %q, got %q", want, output.String())
+	}
+	// Installing with repointing enabled fixes a stale shim.
+	err = os.Remove(toolShim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Symlink(oldExecutable, toolShim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = newTestJKL(t, tempDir, server, jkl.WithRepointShims(true)).Install(context.Background(), "github:jkltest/tool:1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,71 @@
+package jkl
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// SetLocalVersion sets the version of the tool in the ASDF .tool-versions
+// file of the specified directory, which takes precedence over global
+// versions while running tools in that directory or its sub-directories.
+// The file is created if it does not exist. The matched installed version is
+// returned, see SetGlobalVersion for how versions are matched.
+func (j JKL) SetLocalVersion(dir, toolName, version string) (matchedVersion string, err error) {
+	matchedVersion, err = j.getManagedTool(toolName).matchVersion(version)
+	if err != nil {
+		return "", err
+	}
+	filePath := filepath.Join(dir, ASDFConfigFileName)
+	err = WriteASDFToolVersion(filePath, toolName, matchedVersion)
+	if err != nil {
+		return "", fmt.Errorf("cannot set the version of %s in %s: %v", toolName, filePath, err)
+	}
+	return matchedVersion, nil
+}
+
+// resolveVersionArgs returns the tool name and installed version specified
+// by command-line arguments, which are either a tool name and version, or a
+// tool-specification of the form provider:source[:version]. A
+// tool-specification is installed first, so the resulting version is
+// installed.
+func (j JKL) resolveVersionArgs(ctx context.Context, args []string) (toolName, version string, err error) {
+	if len(args) == 1 && strings.Contains(args[0], ":") {
+		toolSpec, err := j.installToolSpec(ctx, args[0])
+		if err != nil {
+			return "", "", err
+		}
+		return toolSpec.name, toolSpec.version, nil
+	}
+	if len(args) != 2 {
+		return "", "", fmt.Errorf("please specify a tool name and version, or a tool-specification to install of the form <provider>:<source>[:version]")
+	}
+	toolName = args[0]
+	version, err = j.getManagedTool(toolName).matchVersion(args[1])
+	if err != nil {
+		return "", "", fmt.Errorf("%v\nTo install a version and use it, specify a tool-specification of the form <provider>:<source>[:version]", err)
+	}
+	return toolName, version, nil
+}
+
+// shellSetEnvCommand returns a shell command that sets the environment
+// variable, for the specified shell such as bash, zsh, or fish.
+func shellSetEnvCommand(shell, name, value string) string {
+	switch filepath.Base(shell) {
+	case "fish":
+		return fmt.Sprintf("set -gx %s %s", name, shellQuote(value))
+	default:
+		return fmt.Sprintf("export %s=%s", name, shellQuote(value))
+	}
+}
+
+// shellQuote single-quotes a string for use in a shell command, if it
+// contains characters other than letters, digits, and punctuation common in
+// versions and paths.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789._-+/:=@,") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,161 @@
+package jkl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errLockHeld is returned by tryLockFile when another process holds the
+// lock.
+var errLockHeld = errors.New("the lock is held by another process")
+
+// fileLock is an advisory lock, which coordinates jkl processes that modify
+// the same tool, shims, or jkl binary.
+type fileLock struct {
+	f    *os.File
+	path string
+}
+
+// lock acquires the named lock, waiting until JKL.lockTimeout for another
+// process to release it. The lock file contains the process ID of the lock
+// holder, which is included in the error returned when the timeout expires.
+// Waiting stops early if the context is done.
+func (j JKL) lock(ctx context.Context, name string) (*fileLock, error) {
+	f, lockPath, err := j.openLockFile(name)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(j.lockTimeout)
+	var loggedWaiting bool
+	for {
+		err = tryLockFile(f)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errLockHeld) {
+			f.Close()
+			return nil, fmt.Errorf("while locking %s: %v", lockPath, err)
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("timed out after %v waiting for %s to be unlocked by another %s process (PID %s)", j.lockTimeout, lockPath, callMeProgName, lockHolder(lockPath))
+		}
+		if !loggedWaiting {
+			debugLog.Printf("waiting for lock %s which is held by PID %s", lockPath, lockHolder(lockPath))
+			loggedWaiting = true
+		}
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, fmt.Errorf("while waiting for %s to be unlocked: %w", lockPath, ctx.Err())
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	return recordLockHolder(f, lockPath)
+}
+
+// tryLock acquires the named lock only if no other process holds it,
+// returning false without waiting otherwise.
+func (j JKL) tryLock(name string) (l *fileLock, ok bool, err error) {
+	f, lockPath, err := j.openLockFile(name)
+	if err != nil {
+		return nil, false, err
+	}
+	err = tryLockFile(f)
+	if errors.Is(err, errLockHeld) {
+		f.Close()
+		debugLog.Printf("not waiting for lock %s which is held by PID %s", lockPath, lockHolder(lockPath))
+		return nil, false, nil
+	}
+	if err != nil {
+		f.Close()
+		return nil, false, fmt.Errorf("while locking %s: %v", lockPath, err)
+	}
+	l, err = recordLockHolder(f, lockPath)
+	if err != nil {
+		return nil, false, err
+	}
+	return l, true, nil
+}
+
+// openLockFile opens the file of the named lock, creating the locks
+// directory and the file if needed.
+func (j JKL) openLockFile(name string) (f *os.File, lockPath string, err error) {
+	if j.locksDir == "" {
+		return nil, "", errors.New("the locks directory is not set")
+	}
+	err = os.MkdirAll(j.locksDir, 0700)
+	if err != nil {
+		return nil, "", err
+	}
+	lockPath = filepath.Join(j.locksDir, name+".lock")
+	f, err = os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, "", err
+	}
+	return f, lockPath, nil
+}
+
+// recordLockHolder writes the process ID to the file of a newly acquired
+// lock, and returns the lock.
+func recordLockHolder(f *os.File, lockPath string) (*fileLock, error) {
+	err := f.Truncate(0)
+	if err == nil {
+		_, err = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	if err != nil {
+		f.Close() // also releases the lock
+		return nil, fmt.Errorf("while recording the lock holder in %s: %v", lockPath, err)
+	}
+	debugLog.Printf("acquired lock %s", lockPath)
+	return &fileLock{
+		f:    f,
+		path: lockPath,
+	}, nil
+}
+
+// lockTool acquires the lock used while installing or uninstalling versions
+// of the specified tool.
+func (j JKL) lockTool(ctx context.Context, toolName string) (*fileLock, error) {
+	return j.lock(ctx, toolLockName(toolName))
+}
+
+// toolLockName returns the name of the lock of the specified tool.
+func toolLockName(toolName string) string {
+	return "tool-" + toolName
+}
+
+// unlock releases the lock. The lock file is not removed, as another
+// process may already be waiting on it.
+func (l *fileLock) unlock() {
+	debugLog.Printf("releasing lock %s", l.path)
+	err := l.f.Truncate(0)
+	if err != nil {
+		debugLog.Printf("cannot clear the lock holder from %s: %v", l.path, err)
+	}
+	err = unlockFile(l.f)
+	if err != nil {
+		debugLog.Printf("cannot unlock %s: %v", l.path, err)
+	}
+	l.f.Close()
+}
+
+// lockHolder returns the process ID recorded in the specified lock file, or
+// "unknown".
+func lockHolder(lockPath string) string {
+	b, err := os.ReadFile(lockPath)
+	if err != nil {
+		return "unknown"
+	}
+	PID := strings.TrimSpace(string(b))
+	if PID == "" {
+		return "unknown"
+	}
+	return PID
+}
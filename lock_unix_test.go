@@ -0,0 +1,54 @@
+//go:build unix
+
+package jkl_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ivanfetch/jkl"
+)
+
+func TestUninstallTimesOutWaitingForLock(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+	lockPath := filepath.Join(tempDir, "locks/tool-tool.lock")
+	err := os.MkdirAll(filepath.Dir(lockPath), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.WriteString("12345\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := jkl.NewJKL(
+		jkl.WithInstallsDir(filepath.Join(tempDir, "installs")),
+		jkl.WithShimsDir(filepath.Join(tempDir, "bin")),
+		jkl.WithLocksDir(filepath.Join(tempDir, "locks")),
+		jkl.WithLockTimeout(200*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = j.Uninstall(context.Background(), "tool")
+	if err == nil {
+		t.Fatal("an error is expected while another process holds the lock")
+	}
+	if !strings.Contains(err.Error(), "PID 12345") {
+		t.Fatalf("want the error to name the PID holding the lock, got: %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+//go:build windows
+
+package jkl
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+const (
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+	errorLockViolation      = syscall.Errno(33)
+)
+
+// lockOverlapped returns the file region which is locked. Windows locks
+// prevent other processes from reading the locked region, so a byte far
+// beyond the process ID written to the lock file is locked, allowing
+// lockHolder to read it.
+func lockOverlapped() *syscall.Overlapped {
+	return &syscall.Overlapped{Offset: 0xffffffff, OffsetHigh: 0x7fffffff}
+}
+
+// tryLockFile acquires an exclusive lock of the file without waiting,
+// returning errLockHeld if another process holds the lock.
+func tryLockFile(f *os.File) error {
+	r, _, err := procLockFileEx.Call(f.Fd(), lockfileExclusiveLock|lockfileFailImmediately, 0, 1, 0, uintptr(unsafe.Pointer(lockOverlapped())))
+	if r != 0 {
+		return nil
+	}
+	if errors.Is(err, errorLockViolation) {
+		return errLockHeld
+	}
+	return err
+}
+
+// unlockFile releases the lock acquired by tryLockFile.
+func unlockFile(f *os.File) error {
+	r, _, err := procUnlockFileEx.Call(f.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(lockOverlapped())))
+	if r != 0 {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,15 @@
+//go:build !integration
+
+package jkl_test
+
+import (
+	"os"
+	"testing"
+)
+
+// TestMain isolates tests from the environment of the machine running them.
+// With the integration build tag, TestMain in script_test.go does this
+// instead.
+func TestMain(m *testing.M) {
+	os.Exit(runWithIsolatedEnvironment(m.Run))
+}
@@ -0,0 +1,291 @@
+package jkl
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+/*
+Some tools are only released as Debian (.deb) or RPM (.rpm) packages.
+Rather than using the system package manager, which requires root, jkl
+extracts the files these packages would install into a bin directory, such
+as usr/bin, and ignores the remaining files like documentation and shell
+completion.
+*/
+
+// isPackageBinaryPath returns true if the specified package member is
+// installed into a directory of executables, such as usr/bin or
+// opt/<vendor>/bin.
+func isPackageBinaryPath(memberPath string) bool {
+	dirName := path.Base(path.Dir(memberPath))
+	return dirName == "bin" || dirName == "sbin"
+}
+
+// decompressingReader returns an io.ReadCloser that decompresses r, if it
+// is compressed using gzip, bzip2, xz, or zstd. Otherwise the returned
+// io.ReadCloser reads r as-is.
+func decompressingReader(r io.Reader) (io.ReadCloser, error) {
+	ftr, fileType, err := NewFileTypeReader(r)
+	if err != nil {
+		return nil, err
+	}
+	debugLog.Printf("package payload type %v\n", fileType)
+	switch fileType {
+	case "gz":
+		return gzip.NewReader(ftr)
+	case "bz2":
+		return io.NopCloser(bzip2.NewReader(ftr)), nil
+	case "xz":
+		xzReader, err := xz.NewReader(ftr)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(xzReader), nil
+	case "zst":
+		zstdReader, err := zstd.NewReader(ftr)
+		if err != nil {
+			return nil, err
+		}
+		return zstdReader.IOReadCloser(), nil
+	default:
+		return io.NopCloser(ftr), nil
+	}
+}
+
+// skipBytes discards n bytes from r, returning io.ErrUnexpectedEOF if r
+// ends early.
+func skipBytes(r io.Reader, n int64) error {
+	_, err := io.CopyN(io.Discard, r, n)
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// extractDebFile extracts executables from the data archive of a Debian
+// package into the destination directory.
+// A Debian package is an ar archive, containing a possibly compressed tar
+// archive named data.tar.
+func (e *extractor) extractDebFile(r io.Reader) error {
+	debugLog.Println("extracting Debian package")
+	magic := make([]byte, 8)
+	_, err := io.ReadFull(r, magic)
+	if err != nil {
+		return fmt.Errorf("while reading Debian package: %w", err)
+	}
+	if string(magic) != "!<arch>\n" {
+		return errors.New("the Debian package is not an ar archive")
+	}
+	header := make([]byte, 60)
+	for {
+		_, err := io.ReadFull(r, header)
+		if errors.Is(err, io.EOF) {
+			return errors.New("the Debian package does not contain a data.tar archive")
+		}
+		if err != nil {
+			return fmt.Errorf("while reading Debian package: %w", err)
+		}
+		// GNU ar terminates member names with a slash.
+		memberName := strings.TrimSuffix(strings.TrimSpace(string(header[0:16])), "/")
+		memberSize, err := strconv.ParseInt(strings.TrimSpace(string(header[48:58])), 10, 64)
+		if err != nil || memberSize < 0 {
+			return fmt.Errorf("invalid size for member %q of Debian package", memberName)
+		}
+		if !strings.HasPrefix(memberName, "data.tar") {
+			debugLog.Printf("skipping Debian package member %q", memberName)
+			// Members are padded to an even size.
+			err = skipBytes(r, memberSize+memberSize%2)
+			if err != nil {
+				return fmt.Errorf("while reading Debian package: %w", err)
+			}
+			continue
+		}
+		debugLog.Printf("extracting Debian package member %q", memberName)
+		dataReader, err := decompressingReader(io.LimitReader(r, memberSize))
+		if err != nil {
+			return err
+		}
+		defer dataReader.Close()
+		ftr, fileType, err := NewFileTypeReader(dataReader)
+		if err != nil {
+			return err
+		}
+		if fileType != "tar" {
+			return fmt.Errorf("the Debian package member %q is of type %q instead of a tar archive", memberName, fileType)
+		}
+		e.includeMember = isPackageBinaryPath
+		err = e.extractTarFile(ftr)
+		if err != nil {
+			return fmt.Errorf("while extracting Debian package data: %v", err)
+		}
+		return nil
+	}
+}
+
+// skipRPMHeader reads past an RPM header structure, which is used for both
+// the package signature and the package header.
+// The signature is padded to a multiple of 8 bytes.
+func skipRPMHeader(r io.Reader, isSignature bool) error {
+	intro := make([]byte, 16)
+	_, err := io.ReadFull(r, intro)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(intro[0:4], []byte{0x8e, 0xad, 0xe8, 0x01}) {
+		return errors.New("invalid RPM header")
+	}
+	numIndexEntries := int64(binary.BigEndian.Uint32(intro[8:12]))
+	storeSize := int64(binary.BigEndian.Uint32(intro[12:16]))
+	headerSize := numIndexEntries*16 + storeSize
+	if isSignature {
+		headerSize += (8 - (16+headerSize)%8) % 8
+	}
+	return skipBytes(r, headerSize)
+}
+
+// extractRPMFile extracts executables from the payload of an RPM package
+// into the destination directory.
+// An RPM package is a lead, signature, and header, followed by a
+// possibly compressed cpio archive.
+func (e *extractor) extractRPMFile(r io.Reader) error {
+	debugLog.Println("extracting RPM package")
+	lead := make([]byte, 96)
+	_, err := io.ReadFull(r, lead)
+	if err != nil {
+		return fmt.Errorf("while reading RPM lead: %w", err)
+	}
+	if !bytes.Equal(lead[0:4], []byte{0xed, 0xab, 0xee, 0xdb}) {
+		return errors.New("the file is not an RPM package")
+	}
+	err = skipRPMHeader(r, true)
+	if err != nil {
+		return fmt.Errorf("while reading RPM signature: %w", err)
+	}
+	err = skipRPMHeader(r, false)
+	if err != nil {
+		return fmt.Errorf("while reading RPM header: %w", err)
+	}
+	payloadReader, err := decompressingReader(r)
+	if err != nil {
+		return err
+	}
+	defer payloadReader.Close()
+	e.includeMember = isPackageBinaryPath
+	err = e.extractCPIOFile(payloadReader)
+	if err != nil {
+		return fmt.Errorf("while extracting RPM payload: %v", err)
+	}
+	return nil
+}
+
+// cpio mode bits for the file type.
+const (
+	cpioTypeMask    = 0170000
+	cpioTypeDir     = 0040000
+	cpioTypeReg     = 0100000
+	cpioTypeSymlink = 0120000
+)
+
+// extractCPIOFile extracts a cpio archive in the "new" ASCII format, which
+// is used by RPM packages, into the destination directory.
+// Files are extracted in a flat hierarchy, without their sub-directories.
+func (e *extractor) extractCPIOFile(r io.Reader) error {
+	debugLog.Println("extracting cpio")
+	header := make([]byte, 110)
+	for {
+		_, err := io.ReadFull(r, header)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return io.ErrUnexpectedEOF // The archive should end with a trailer entry.
+			}
+			return err
+		}
+		magic := string(header[0:6])
+		if magic != "070701" && magic != "070702" {
+			return fmt.Errorf("unsupported cpio format %q", magic)
+		}
+		hexField := func(offset int) (int64, error) {
+			return strconv.ParseInt(string(header[offset:offset+8]), 16, 64)
+		}
+		mode, err := hexField(14)
+		if err != nil {
+			return fmt.Errorf("invalid cpio mode: %v", err)
+		}
+		fileSize, err := hexField(54)
+		if err != nil {
+			return fmt.Errorf("invalid cpio file size: %v", err)
+		}
+		nameSize, err := hexField(94)
+		if err != nil || nameSize < 1 || nameSize > 4096 {
+			return fmt.Errorf("invalid cpio name size %d: %v", nameSize, err)
+		}
+		// The name is padded so that the header plus name are a multiple of
+		// four bytes.
+		nameBuffer := make([]byte, nameSize+(4-(110+nameSize)%4)%4)
+		_, err = io.ReadFull(r, nameBuffer)
+		if err != nil {
+			return err
+		}
+		name := string(bytes.TrimRight(nameBuffer[:nameSize], "\x00"))
+		if name == "TRAILER!!!" {
+			debugLog.Println("end of cpio file")
+			return nil
+		}
+		memberPath, err := archiveMemberPath(name)
+		if err != nil {
+			return err
+		}
+		data := &io.LimitedReader{R: r, N: fileSize}
+		switch {
+		case mode&cpioTypeMask == cpioTypeDir:
+			debugLog.Printf("skipping directory %q", name)
+		case !e.includes(memberPath):
+			debugLog.Printf("skipping %q which is not included for extraction", name)
+		case mode&cpioTypeMask == cpioTypeReg:
+			if fileSize > e.maxBytes-e.extractedBytes {
+				return fmt.Errorf("aborting extraction at %q, the total extracted size would exceed the maximum of %d bytes", name, e.maxBytes)
+			}
+			err = e.countFile(name)
+			if err != nil {
+				return err
+			}
+			err = e.saveAs(data, e.flatDestPath(memberPath), fs.FileMode(mode).Perm())
+			if err != nil {
+				return err
+			}
+		case mode&cpioTypeMask == cpioTypeSymlink:
+			// The content of a cpio symlink is its target.
+			linkTarget, err := io.ReadAll(io.LimitReader(data, 4096))
+			if err != nil {
+				return err
+			}
+			err = e.extractSymlink(memberPath, string(linkTarget))
+			if err != nil {
+				return err
+			}
+		default:
+			debugLog.Printf("skipping special file %q with mode %o", name, mode)
+		}
+		// File data is padded to a multiple of four bytes.
+		err = skipBytes(data, data.N)
+		if err != nil {
+			return err
+		}
+		err = skipBytes(r, (4-fileSize%4)%4)
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package jkl
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// DownloadProgress describes the progress of a download.
+type DownloadProgress struct {
+	URL             string
+	FileName        string
+	BytesDownloaded int64
+	TotalBytes      int64 // -1 if the size is unknown
+	Started         time.Time
+	Done            bool
+}
+
+// BytesPerSecond returns the average download rate.
+func (p DownloadProgress) BytesPerSecond() float64 {
+	elapsed := time.Since(p.Started).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(p.BytesDownloaded) / elapsed
+}
+
+// ETA returns the estimated time remaining, or false if it cannot be
+// estimated.
+func (p DownloadProgress) ETA() (time.Duration, bool) {
+	rate := p.BytesPerSecond()
+	if p.TotalBytes < 0 || rate <= 0 {
+		return 0, false
+	}
+	return time.Duration(float64(p.TotalBytes-p.BytesDownloaded) / rate * float64(time.Second)), true
+}
+
+// ProgressFunc receives progress events while a file is downloaded. The
+// final event has Done set to true.
+type ProgressFunc func(DownloadProgress)
+
+// progressReader reports progress while reading a download.
+type progressReader struct {
+	r        io.Reader
+	progress *DownloadProgress
+	report   ProgressFunc
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.progress.BytesDownloaded += int64(n)
+	if n > 0 && p.report != nil {
+		p.report(*p.progress)
+	}
+	return n, err
+}
+
+// isTerminal returns true if the file is a terminal.
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return stat.Mode()&os.ModeCharDevice != 0
+}
+
+// newTerminalProgressBar returns a ProgressFunc that draws a progress bar,
+// redrawing it at most every tenth of a second.
+func newTerminalProgressBar(w io.Writer) ProgressFunc {
+	var lastDrawn time.Time
+	return func(p DownloadProgress) {
+		if !p.Done && time.Since(lastDrawn) < 100*time.Millisecond {
+			return
+		}
+		lastDrawn = time.Now()
+		fmt.Fprintf(w, "\r%s\033[K", formatProgress(p, 30))
+		if p.Done {
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+// formatProgress returns a one-line progress bar of the specified width,
+// followed by the bytes downloaded, rate, and estimated time remaining.
+func formatProgress(p DownloadProgress, width int) string {
+	var b strings.Builder
+	b.WriteString(p.FileName + " ")
+	if p.TotalBytes > 0 {
+		filled := int(int64(width) * p.BytesDownloaded / p.TotalBytes)
+		if filled > width {
+			filled = width
+		}
+		fmt.Fprintf(&b, "[%s%s] %3d%% %s/%s", strings.Repeat("=", filled), strings.Repeat(" ", width-filled), 100*p.BytesDownloaded/p.TotalBytes, formatByteSize(p.BytesDownloaded), formatByteSize(p.TotalBytes))
+	} else {
+		b.WriteString(formatByteSize(p.BytesDownloaded))
+	}
+	fmt.Fprintf(&b, " %s/s", formatByteSize(int64(p.BytesPerSecond())))
+	if p.Done {
+		fmt.Fprintf(&b, " in %v", time.Since(p.Started).Round(100*time.Millisecond))
+	} else if ETA, ok := p.ETA(); ok {
+		fmt.Fprintf(&b, " ETA %v", ETA.Round(time.Second))
+	}
+	return b.String()
+}
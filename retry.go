@@ -0,0 +1,144 @@
+package jkl
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// requestDoer sends an HTTP request, like http.Client.Do.
+type requestDoer func(*http.Request) (*http.Response, error)
+
+// retryPolicy determines how failed HTTP requests are retried.
+type retryPolicy struct {
+	maxRetries int
+	baseDelay  time.Duration // doubled for each retry
+	maxDelay   time.Duration // the longest single delay, also applied to Retry-After
+}
+
+// defaultRetryPolicy retries a request three times, over a few seconds.
+var defaultRetryPolicy = retryPolicy{
+	maxRetries: 3,
+	baseDelay:  500 * time.Millisecond,
+	maxDelay:   30 * time.Second,
+}
+
+// backoff returns the delay before the specified retry, starting at one. The
+// delay grows exponentially, and is randomized between half and all of
+// that amount so multiple clients do not retry in lockstep.
+func (p retryPolicy) backoff(retry int) time.Duration {
+	delay := p.baseDelay << (retry - 1)
+	if delay > p.maxDelay || delay <= 0 {
+		delay = p.maxDelay
+	}
+	if delay < 2 {
+		return delay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)))
+}
+
+// isRetryableStatus returns true for HTTP status codes which indicate a
+// transient server problem.
+func isRetryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfter returns the delay requested by the Retry-After header of the
+// response, which may be a number of seconds or an HTTP date.
+func retryAfter(resp *http.Response) (delay time.Duration, found bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	seconds, err := strconv.Atoi(value)
+	if err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		debugLog.Printf("ignoring invalid Retry-After header %q", value)
+		return 0, false
+	}
+	delay = time.Until(date)
+	if delay < 0 {
+		delay = 0
+	}
+	return delay, true
+}
+
+// do sends the HTTP request, retrying connection errors and transient server
+// errors using exponential backoff. A response asking the client to slow down,
+// with HTTP 429 or 403 and a Retry-After header, is retried after the
+// requested delay if that is no longer than the maximum delay.
+// Only requests without a body are retried.
+func (p retryPolicy) do(hc *http.Client, req *http.Request) (*http.Response, error) {
+	for retry := 0; ; retry++ {
+		resp, err := hc.Do(req)
+		if retry >= p.maxRetries || req.Body != nil {
+			return resp, err
+		}
+		var delay time.Duration
+		switch {
+		case err != nil:
+			if req.Context().Err() != nil {
+				return nil, err
+			}
+			delay = p.backoff(retry + 1)
+			debugLog.Printf("retrying %s %s in %v after error: %v", req.Method, req.URL, delay, err)
+		case isRetryableStatus(resp.StatusCode):
+			delay = p.backoff(retry + 1)
+			debugLog.Printf("retrying %s %s in %v after HTTP %d", req.Method, req.URL, delay, resp.StatusCode)
+		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusForbidden:
+			retryAfterDelay, found := retryAfter(resp)
+			if !found || retryAfterDelay > p.maxDelay {
+				return resp, nil
+			}
+			delay = retryAfterDelay
+			debugLog.Printf("retrying %s %s in %v as requested by the server after HTTP %d", req.Method, req.URL, delay, resp.StatusCode)
+		default:
+			return resp, nil
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// githubRateLimitError returns an error describing how to proceed when the
+// Github response indicates a rate limit has been exceeded, or nil if the
+// response is not rate-limited.
+func githubRateLimitError(resp *http.Response, hasToken bool) error {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+	var waitPhrase string
+	if delay, found := retryAfter(resp); found {
+		waitPhrase = fmt.Sprintf(", please try again in %v", delay.Round(time.Second))
+	} else if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		resetSeconds, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+		if err == nil {
+			reset := time.Unix(resetSeconds, 0)
+			waitPhrase = fmt.Sprintf(", and resets at %s (in %v)", reset.Format(time.Kitchen), time.Until(reset).Round(time.Second))
+		}
+	} else if resp.StatusCode == http.StatusForbidden {
+		// A 403 without rate-limit headers is a permission problem.
+		return nil
+	}
+	if hasToken {
+		return fmt.Errorf("the Github API rate limit for your token has been exceeded (HTTP %d for %s)%s", resp.StatusCode, resp.Request.URL.Path, waitPhrase)
+	}
+	return fmt.Errorf("the Github API rate limit for unauthenticated requests has been exceeded (HTTP %d for %s)%s. Authenticated requests have a higher rate limit - set the GH_TOKEN environment variable to a Github personal access token, or log in using the gh CLI", resp.StatusCode, resp.Request.URL.Path, waitPhrase)
+}
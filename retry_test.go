@@ -0,0 +1,183 @@
+package jkl_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ivanfetch/jkl"
+)
+
+// scriptedResponse is one response from a scriptedServer.
+type scriptedResponse struct {
+	statusCode     int
+	headers        map[string]string
+	dropConnection bool
+}
+
+// newScriptedServer returns an httptest.Server which sends the specified
+// responses in order, then responds to further requests with HTTP 200 and
+// the specified body. The returned function reports the number of requests
+// received.
+func newScriptedServer(t *testing.T, body string, responses ...scriptedResponse) (server *httptest.Server, numRequests func() int) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests int
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		thisRequest := requests
+		mu.Unlock()
+		if thisRequest > len(responses) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, body)
+			return
+		}
+		response := responses[thisRequest-1]
+		if response.dropConnection {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Errorf("hijacking connection: %v", err)
+				return
+			}
+			conn.Close()
+			return
+		}
+		for k, v := range response.headers {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(response.statusCode)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+}
+
+func TestRetries(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		description     string
+		responses       []scriptedResponse
+		wantRequests    int
+		wantErrContains string // an empty string expects no error
+	}{
+		{
+			description: "transient server errors then success",
+			responses: []scriptedResponse{
+				{statusCode: http.StatusServiceUnavailable},
+				{statusCode: http.StatusBadGateway},
+			},
+			wantRequests: 3,
+		},
+		{
+			description: "dropped connection then success",
+			responses: []scriptedResponse{
+				{dropConnection: true},
+			},
+			wantRequests: 2,
+		},
+		{
+			description: "server errors exceed the retries",
+			responses: []scriptedResponse{
+				{statusCode: http.StatusInternalServerError},
+				{statusCode: http.StatusInternalServerError},
+				{statusCode: http.StatusInternalServerError},
+				{statusCode: http.StatusInternalServerError},
+			},
+			wantRequests:    4,
+			wantErrContains: "HTTP 500",
+		},
+		{
+			description: "too many requests with Retry-After then success",
+			responses: []scriptedResponse{
+				{statusCode: http.StatusTooManyRequests, headers: map[string]string{"Retry-After": "0"}},
+			},
+			wantRequests: 2,
+		},
+		{
+			description: "bad request is not retried",
+			responses: []scriptedResponse{
+				{statusCode: http.StatusBadRequest},
+			},
+			wantRequests:    1,
+			wantErrContains: "HTTP 400",
+		},
+	}
+	clients := []struct {
+		name   string
+		exists func(apiHost string) (bool, error)
+	}{
+		{
+			name: "Github",
+			exists: func(apiHost string) (bool, error) {
+				g, err := jkl.NewGithubRepo("jkltest/tool", jkl.WithAPIHost(apiHost), jkl.WithRetries(3, time.Millisecond))
+				if err != nil {
+					return false, err
+				}
+				return g.Exists(context.Background())
+			},
+		},
+		{
+			name: "Hashicorp",
+			exists: func(apiHost string) (bool, error) {
+				h, err := jkl.NewHashicorpProduct("tool", jkl.WithHashicorpAPIHost(apiHost), jkl.WithHashicorpRetries(3, time.Millisecond))
+				if err != nil {
+					return false, err
+				}
+				return h.Exists(context.Background())
+			},
+		},
+	}
+	for _, client := range clients {
+		client := client
+		for _, tc := range testCases {
+			tc := tc
+			t.Run(client.name+" "+tc.description, func(t *testing.T) {
+				t.Parallel()
+				server, numRequests := newScriptedServer(t, `["tool"]`, tc.responses...)
+				_, err := client.exists(server.URL)
+				if tc.wantErrContains == "" && err != nil {
+					t.Fatal(err)
+				}
+				if tc.wantErrContains != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErrContains)) {
+					t.Fatalf("want an error containing %q, got: %v", tc.wantErrContains, err)
+				}
+				if numRequests() != tc.wantRequests {
+					t.Fatalf("want %d requests, got %d", tc.wantRequests, numRequests())
+				}
+			})
+		}
+	}
+}
+
+func TestGithubRateLimitExceeded(t *testing.T) {
+	// Not parallel, to unset GH_TOKEN.
+	t.Setenv("GH_TOKEN", "")
+	server, numRequests := newScriptedServer(t, `{}`, scriptedResponse{
+		statusCode: http.StatusForbidden,
+		headers: map[string]string{
+			"X-RateLimit-Remaining": "0",
+			"X-RateLimit-Reset":     strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10),
+		},
+	})
+	g, err := jkl.NewGithubRepo("jkltest/tool", jkl.WithAPIHost(server.URL), jkl.WithRetries(3, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = g.Exists(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "rate limit") || !strings.Contains(err.Error(), "GH_TOKEN") {
+		t.Fatalf("want an error explaining the rate limit and GH_TOKEN, got: %v", err)
+	}
+	if numRequests() != 1 {
+		t.Fatalf("want the rate-limited request to not be retried, got %d requests", numRequests())
+	}
+}
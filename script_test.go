@@ -13,16 +13,32 @@ import (
 	"github.com/rogpeppe/go-internal/testscript"
 )
 
+// githubToken is read before tests are isolated from the environment, so
+// scripts which install tools from Github avoid its lower rate limit for
+// unauthenticated requests.
+var githubToken = os.Getenv("GH_TOKEN")
+
 var testScriptSetup func(*testscript.Env) error = func(e *testscript.Env) error {
-	e.Vars = append(e.Vars, fmt.Sprintf("GH_TOKEN=%s", os.Getenv("GH_TOKEN")))
+	e.Vars = append(e.Vars, fmt.Sprintf("GH_TOKEN=%s", githubToken))
 	return nil
 }
 
+// isolatedTestingM runs tests isolated from the environment, after
+// testscript.RunMain has determined that the test binary is not running a
+// command of a script.
+type isolatedTestingM struct {
+	m *testing.M
+}
+
+func (i isolatedTestingM) Run() int {
+	return runWithIsolatedEnvironment(i.m.Run)
+}
+
 func TestMain(m *testing.M) {
 	// Map binary names called by TestScript scripts, to run jkl.
 	// This causes TestScript to symlink these binary names, affectively doing the
 	// work of jkl.createShim()
-	os.Exit(testscript.RunMain(m, map[string]func() int{
+	os.Exit(testscript.RunMain(isolatedTestingM{m}, map[string]func() int{
 		"jkl": jkl.Main,
 		// List tools that jkl will install in testdata/script/* tests.
 		// Using `exec toolname` in a .txtar file that is not listed here, will
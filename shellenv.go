@@ -0,0 +1,178 @@
+package jkl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// shellEnvVarsName is the environment variable which lists the JKL_<tool>
+// environment variables set by ShellEnv, so they can be distinguished from
+// those set by the user, such as via `jkl use`.
+const shellEnvVarsName = "_JKL_ENV_VARS"
+
+// shellHooks are shell snippets which run `jkl env` before each prompt, by
+// shell name. The quoted path to the jkl binary replaces %[1]s.
+var shellHooks = map[string]string{
+	"bash": `_jkl_hook() {
+  local previous_exit_status=$?
+  eval "$(%[1]s env --shell bash)"
+  return $previous_exit_status
+}
+if [[ ";${PROMPT_COMMAND:-};" != *";_jkl_hook;"* ]]; then
+  PROMPT_COMMAND="_jkl_hook${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+fi
+`,
+	"zsh": `_jkl_hook() {
+  eval "$(%[1]s env --shell zsh)"
+}
+typeset -ag precmd_functions
+if (( ! ${precmd_functions[(I)_jkl_hook]} )); then
+  precmd_functions=(_jkl_hook $precmd_functions)
+fi
+`,
+	"fish": `function _jkl_hook --on-event fish_prompt
+    %[1]s env --shell fish | source
+end
+`,
+}
+
+// checkShell returns an error if the specified shell is not one whose syntax
+// jkl outputs.
+func checkShell(shell string) error {
+	if shell == "" {
+		return errors.New("the shell is not known as the SHELL environment variable is not set, please specify bash, zsh, or fish")
+	}
+	if _, ok := shellHooks[filepath.Base(shell)]; !ok {
+		return fmt.Errorf("unsupported shell %q, please specify bash, zsh, or fish", shell)
+	}
+	return nil
+}
+
+// ShellHook writes a snippet for the specified shell, which sets the versions
+// of tools before each prompt using the output of `jkl env`. Versions then
+// follow directory changes and edits to .tool-versions files.
+func (j JKL) ShellHook(output io.Writer, shell string) error {
+	err := checkShell(shell)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(output, shellHooks[filepath.Base(shell)], shellQuote(j.executable))
+	return err
+}
+
+// ShellEnv writes commands for the specified shell, which add the shims
+// directory to the PATH environment variable, and set the JKL_<tool>
+// environment variable of each installed tool to its version specified by
+// .tool-versions files or the global version.
+// Variables set by the previous output of ShellEnv are unset when a version is
+// no longer specified. Other JKL_<tool> environment variables, such as those
+// set using `jkl use`, are left as-is.
+func (j JKL) ShellEnv(output io.Writer, shell string) error {
+	err := checkShell(shell)
+	if err != nil {
+		return err
+	}
+	commands := make([]string, 0)
+	shimsDirInPath, err := directoryInPath(j.shimsDir)
+	if err != nil {
+		return err
+	}
+	if !shimsDirInPath {
+		commands = append(commands, shellPrependPathCommand(shell, j.shimsDir))
+	}
+	previousVars := strings.Fields(os.Getenv(shellEnvVarsName))
+	toolNames, err := j.listInstalledTools()
+	if err != nil {
+		return err
+	}
+	setVars := make([]string, 0, len(toolNames))
+	for _, toolName := range toolNames {
+		tool := j.getManagedTool(toolName)
+		envVarName := tool.envVarName()
+		if os.Getenv(envVarName) != "" && !slices.Contains(previousVars, envVarName) {
+			debugLog.Printf("not changing %s which was not set by %s env", envVarName, callMeProgName)
+			continue
+		}
+		version, _, ok, err := tool.configuredVersion()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		commands = append(commands, shellSetEnvCommand(shell, envVarName, version))
+		setVars = append(setVars, envVarName)
+	}
+	for _, previousVar := range previousVars {
+		if !slices.Contains(setVars, previousVar) {
+			commands = append(commands, shellUnsetEnvCommand(shell, previousVar))
+		}
+	}
+	commands = append(commands, shellSetEnvCommand(shell, shellEnvVarsName, strings.Join(setVars, " ")))
+	for _, command := range commands {
+		_, err = fmt.Fprintln(output, command)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ShellUse writes a command for the specified shell, which sets the JKL_<tool>
+// environment variable to the version specified by args, as described by
+// resolveVersionArgs. If the variable was set by ShellEnv, it is also removed
+// from the variables that ShellEnv manages, so the version is not reset by
+// the next run of ShellEnv.
+func (j JKL) ShellUse(ctx context.Context, output io.Writer, shell string, args []string) error {
+	err := checkShell(shell)
+	if err != nil {
+		return err
+	}
+	toolName, version, err := j.resolveVersionArgs(ctx, args)
+	if err != nil {
+		return err
+	}
+	envVarName := j.getManagedTool(toolName).envVarName()
+	commands := []string{shellSetEnvCommand(shell, envVarName, version)}
+	previousVars := strings.Fields(os.Getenv(shellEnvVarsName))
+	if slices.Contains(previousVars, envVarName) {
+		setVars := slices.DeleteFunc(previousVars, func(name string) bool { return name == envVarName })
+		commands = append(commands, shellSetEnvCommand(shell, shellEnvVarsName, strings.Join(setVars, " ")))
+	}
+	for _, command := range commands {
+		_, err = fmt.Fprintln(output, command)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shellUnsetEnvCommand returns a shell command that unsets the environment
+// variable, for the specified shell such as bash, zsh, or fish.
+func shellUnsetEnvCommand(shell, name string) string {
+	switch filepath.Base(shell) {
+	case "fish":
+		return fmt.Sprintf("set -e %s", name)
+	default:
+		return fmt.Sprintf("unset %s", name)
+	}
+}
+
+// shellPrependPathCommand returns a shell command that adds the directory to
+// the beginning of the PATH environment variable, for the specified shell
+// such as bash, zsh, or fish.
+func shellPrependPathCommand(shell, dir string) string {
+	switch filepath.Base(shell) {
+	case "fish":
+		return fmt.Sprintf("set -gx PATH %s $PATH", shellQuote(dir))
+	default:
+		return fmt.Sprintf(`export PATH=%s:"$PATH"`, shellQuote(dir))
+	}
+}
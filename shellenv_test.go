@@ -0,0 +1,159 @@
+package jkl_test
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ivanfetch/jkl"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var updateGoldenFiles = flag.Bool("update", false, "update golden files in testdata/shell")
+
+// compareGoldenFile compares got with the content of the specified golden
+// file in testdata/shell, first updating the file if the -update flag is set.
+func compareGoldenFile(t *testing.T, fileName, got string) {
+	t.Helper()
+	goldenPath := filepath.Join("testdata/shell", fileName)
+	if *updateGoldenFiles {
+		err := os.WriteFile(goldenPath, []byte(got), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(want) != got {
+		t.Fatalf("want vs. got %s: %s", goldenPath, cmp.Diff(string(want), got))
+	}
+}
+
+func TestShellHook(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		shell       string
+		expectError bool
+	}{
+		{shell: "bash"},
+		{shell: "/bin/zsh"},
+		{shell: "fish"},
+		{shell: "tcsh", expectError: true},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.shell, func(t *testing.T) {
+			t.Parallel()
+			j := newTestJKL(t, t.TempDir(), newFakeGithubServer(t, "jkltest/tool", "v1.0.0"))
+			var output bytes.Buffer
+			err := j.ShellHook(&output, tc.shell)
+			if err != nil && !tc.expectError {
+				t.Fatal(err)
+			}
+			if err == nil && tc.expectError {
+				t.Fatal("an error is expected")
+			}
+			if tc.expectError {
+				return
+			}
+			got := strings.ReplaceAll(output.String(), j.GetExecutable(), "/usr/local/bin/jkl")
+			compareGoldenFile(t, "hook."+filepath.Base(tc.shell)+".golden", got)
+		})
+	}
+}
+
+func TestShellEnv(t *testing.T) {
+	// Not parallel, as the environment is changed.
+	tempDir := t.TempDir()
+	toolServer := newFakeGithubServer(t, "jkltest/tool", "v1.0.0", "v1.1.0")
+	otherServer := newFakeGithubServer(t, "jkltest/other", "v2.0.0")
+	j := newTestJKL(t, tempDir, toolServer)
+	for _, spec := range []string{"github:jkltest/tool:1.0.0", "github:jkltest/tool:1.1.0"} {
+		_, err := j.Install(context.Background(), spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := newTestJKL(t, tempDir, otherServer).Install(context.Background(), "github:jkltest/other:2.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = jkl.WriteASDFToolVersion(filepath.Join(tempDir, "tool-versions"), "tool", "v1.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = jkl.WriteASDFToolVersion(filepath.Join(tempDir, "tool-versions"), "other", "latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// JKL_OTHER was set outside of jkl env, and is not changed.
+	t.Setenv("JKL_OTHER", "v2.0.0")
+	// JKL_REMOVED was set by a previous jkl env, for a tool that is no longer
+	// installed.
+	t.Setenv("JKL_REMOVED", "v1.0.0")
+	t.Setenv("_JKL_ENV_VARS", "JKL_TOOL JKL_REMOVED")
+	t.Setenv("PATH", "/usr/bin:/bin")
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		var output bytes.Buffer
+		err := j.ShellEnv(&output, shell)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := strings.ReplaceAll(output.String(), tempDir, "/home/user/.jkl")
+		compareGoldenFile(t, "env."+shell+".golden", got)
+	}
+	for _, shell := range []string{"tcsh", ""} {
+		err := j.ShellEnv(io.Discard, shell)
+		if err == nil {
+			t.Fatalf("an error is expected for shell %q", shell)
+		}
+	}
+}
+
+func TestShellUseAfterShellEnv(t *testing.T) {
+	// Not parallel, as the environment is changed.
+	tempDir := t.TempDir()
+	j := newTestJKL(t, tempDir, newFakeGithubServer(t, "jkltest/tool", "v1.0.0", "v1.1.0"))
+	for _, spec := range []string{"github:jkltest/tool:1.0.0", "github:jkltest/tool:1.1.0"} {
+		_, err := j.Install(context.Background(), spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := jkl.WriteASDFToolVersion(filepath.Join(tempDir, "tool-versions"), "tool", "v1.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// As set by a previous jkl env.
+	t.Setenv("JKL_TOOL", "v1.1.0")
+	t.Setenv("_JKL_ENV_VARS", "JKL_TOOL")
+	t.Setenv("PATH", filepath.Join(tempDir, "bin"))
+	var output bytes.Buffer
+	err = j.ShellUse(context.Background(), &output, "bash", []string{"tool", "1.0.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "export JKL_TOOL=v1.0.0\nexport _JKL_ENV_VARS=''\n"
+	if output.String() != want {
+		t.Fatalf("want jkl use output %q, got %q", want, output.String())
+	}
+	// As evaluated by the shell.
+	t.Setenv("JKL_TOOL", "v1.0.0")
+	t.Setenv("_JKL_ENV_VARS", "")
+	output.Reset()
+	err = j.ShellEnv(&output, "bash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(output.String(), "JKL_TOOL") {
+		t.Fatalf("want jkl env to leave the version set by jkl use, got output:\n%s", output.String())
+	}
+}
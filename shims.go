@@ -0,0 +1,218 @@
+package jkl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// shimState describes an entry in the shims directory.
+type shimState int
+
+const (
+	shimMissing shimState = iota
+	shimCurrent           // a symlink to the jkl executable
+	shimStale             // a symlink to a different or missing jkl binary, such as after jkl was moved
+	shimForeign           // not created by jkl
+)
+
+// inspectShim returns the state of the specified shim, and the target of the
+// symlink if the shim is one.
+// A symlink to a file named jkl is considered to have been created by jkl.
+func (j JKL) inspectShim(shimPath string) (state shimState, target string, err error) {
+	stat, err := os.Lstat(shimPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return shimMissing, "", nil
+	}
+	if err != nil {
+		return shimMissing, "", fmt.Errorf("while looking for existing shim %s: %v", shimPath, err)
+	}
+	if stat.Mode()&fs.ModeSymlink == 0 {
+		return shimForeign, "", nil
+	}
+	target, err = os.Readlink(shimPath)
+	if err != nil {
+		return shimMissing, "", fmt.Errorf("while reading shim symlink %s: %v", shimPath, err)
+	}
+	shimDest, err := filepath.EvalSymlinks(shimPath)
+	if err == nil {
+		shimDestStat, err := os.Stat(shimDest)
+		if err != nil {
+			return shimMissing, "", err
+		}
+		executableStat, err := os.Stat(j.GetExecutable())
+		if err != nil {
+			return shimMissing, "", err
+		}
+		if os.SameFile(shimDestStat, executableStat) {
+			return shimCurrent, target, nil
+		}
+	} else {
+		debugLog.Printf("the shim %s is dangling: %v", shimPath, err)
+	}
+	if filepath.Base(target) == callMeProgName || filepath.Base(shimDest) == callMeProgName {
+		return shimStale, target, nil
+	}
+	return shimForeign, target, nil
+}
+
+// ensureShim creates or verifies the shim of the specified tool, returning
+// whether the shim was created or repointed. A stale shim is only repointed
+// if repoint is true. The caller should hold the shims lock.
+func (j JKL) ensureShim(toolName string, repoint bool) (changed bool, err error) {
+	shimPath := filepath.Join(j.shimsDir, toolName)
+	state, target, err := j.inspectShim(shimPath)
+	if err != nil {
+		return false, err
+	}
+	switch state {
+	case shimMissing:
+		debugLog.Printf("Creating shim %s -> %s\n", toolName, j.GetExecutable())
+		err = os.Symlink(j.GetExecutable(), shimPath)
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	case shimCurrent:
+		debugLog.Printf("shim for %s already exists", shimPath)
+		return false, nil
+	case shimStale:
+		if !repoint {
+			return false, fmt.Errorf("shim %s already exists but points to %q instead of %q, run `%s reshim` to repoint shims to this jkl binary", shimPath, target, j.GetExecutable(), callMeProgName)
+		}
+		err = j.repointShim(shimPath)
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	if target == "" {
+		return false, fmt.Errorf("not overwriting existing incorrect shim %s which should be a symlink to %q", shimPath, j.GetExecutable())
+	}
+	return false, fmt.Errorf("shim %s already exists but points to %q instead of %q", shimPath, target, j.GetExecutable())
+}
+
+// repointShim replaces the specified shim with a symlink to the jkl
+// executable. The new symlink is renamed over the shim, so the shim is not
+// missing while it is replaced.
+func (j JKL) repointShim(shimPath string) error {
+	debugLog.Printf("repointing shim %s -> %s", shimPath, j.GetExecutable())
+	tempPath := filepath.Join(filepath.Dir(shimPath), "."+filepath.Base(shimPath)+".new")
+	err := os.Remove(tempPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	err = os.Symlink(j.GetExecutable(), tempPath)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tempPath, shimPath)
+	if err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("while repointing shim %s: %v", shimPath, err)
+	}
+	return nil
+}
+
+// Reshim creates or repoints the shims of all installed tools, and removes
+// shims of tools which have no versions installed. Shims which were not
+// created by jkl are left as-is, with a warning. Changes are described to
+// output. The version index of each installed tool is also rebuilt.
+func (j JKL) Reshim(ctx context.Context, output io.Writer) error {
+	err := os.MkdirAll(j.shimsDir, 0700)
+	if err != nil {
+		return err
+	}
+	err = j.updateVersionIndexes(ctx)
+	if err != nil {
+		return err
+	}
+	shimsLock, err := j.lock(ctx, "shims")
+	if err != nil {
+		return err
+	}
+	defer shimsLock.unlock()
+	toolNames, err := j.listInstalledTools()
+	if err != nil {
+		return err
+	}
+	var changes int
+	for _, toolName := range toolNames {
+		shimPath := filepath.Join(j.shimsDir, toolName)
+		state, _, err := j.inspectShim(shimPath)
+		if err != nil {
+			return err
+		}
+		if state == shimForeign {
+			fmt.Fprintf(output, "WARNING: not replacing %s which was not created by %s, so %s cannot be run by its shim\n", shimPath, callMeProgName, toolName)
+			continue
+		}
+		changed, err := j.ensureShim(toolName, true)
+		if err != nil {
+			return err
+		}
+		if changed {
+			changes++
+			if state == shimStale {
+				fmt.Fprintf(output, "Repointed shim %s\n", shimPath)
+			} else {
+				fmt.Fprintf(output, "Created shim %s\n", shimPath)
+			}
+		}
+	}
+	entries, err := os.ReadDir(j.shimsDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		// The jkl binary, or a link to it, may also be in the shims directory.
+		if entry.Name() == callMeProgName || strings.HasPrefix(entry.Name(), ".") || slices.Contains(toolNames, entry.Name()) {
+			continue
+		}
+		shimPath := filepath.Join(j.shimsDir, entry.Name())
+		state, _, err := j.inspectShim(shimPath)
+		if err != nil {
+			return err
+		}
+		if state != shimCurrent && state != shimStale {
+			debugLog.Printf("not removing %s which was not created by %s", shimPath, callMeProgName)
+			continue
+		}
+		err = os.Remove(shimPath)
+		if err != nil {
+			return fmt.Errorf("while removing orphaned shim %s: %v", shimPath, err)
+		}
+		changes++
+		fmt.Fprintf(output, "Removed shim %s, as no versions of %s are installed\n", shimPath, entry.Name())
+	}
+	if changes == 0 {
+		fmt.Fprintln(output, "All shims are up to date")
+	}
+	return nil
+}
+
+// updateVersionIndexes rebuilds the version index of each installed tool,
+// such as after upgrading from a version of jkl which did not maintain the
+// indexes. The lock of each tool is acquired before, never while, holding the
+// shims lock, in the same order as Install.
+func (j JKL) updateVersionIndexes(ctx context.Context) error {
+	toolNames, err := j.listInstalledTools()
+	if err != nil {
+		return err
+	}
+	for _, toolName := range toolNames {
+		toolLock, err := j.lockTool(ctx, toolName)
+		if err != nil {
+			return err
+		}
+		j.getManagedTool(toolName).updateVersionIndex()
+		toolLock.unlock()
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package jkl
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/fs"
@@ -31,36 +32,137 @@ func (j JKL) getManagedTool(name string) *managedTool {
 // run executes the desired version of the specified tool. The desired version is
 // determined via user configuration.
 func (t managedTool) Run(args []string) error {
-	desiredVersion, ok, err := t.desiredVersion()
+	installedCommandPath, err := t.commandPath()
 	if err != nil {
 		return err
 	}
+	err = ExecCommand(append([]string{installedCommandPath}, args...))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// commandPath returns the path to the desired version of the tool, which is
+// run by its shim.
+func (t managedTool) commandPath() (installedCommandPath string, err error) {
+	toolVersion, err := t.currentVersion()
+	if err != nil {
+		return "", err
+	}
+	return toolVersion.Path, nil
+}
+
+// noVersionSelectedError is returned when multiple versions of a tool are
+// installed, and none is specified.
+type noVersionSelectedError struct {
+	toolName, envVarName string
+}
+
+func (e noVersionSelectedError) Error() string {
+	return fmt.Sprintf(`please specify which version of %[1]s you would like to run, by setting the %[2]s environment variable to a valid version, or to "latest" to use the latest installed version. To set a default version, run: %[3]s global %[1]s <version>`, e.toolName, e.envVarName, callMeProgName)
+}
+
+// versionNotInstalledError is returned when the version of a tool that its
+// shim would run is not installed.
+type versionNotInstalledError struct {
+	toolName, version string
+	source            versionSource
+}
+
+func (e versionNotInstalledError) Error() string {
+	return fmt.Sprintf("version %s of %s is not installed please see the `%s install` command to install it, this version is specified by %s", e.version, e.toolName, callMeProgName, e.source)
+}
+
+// ToolVersion is the version of a tool that its shim runs, and how that
+// version was selected.
+type ToolVersion struct {
+	Tool    string
+	Version string
+	Path    string // the installed binary of this version
+	Source  string // describes where the version was specified
+}
+
+// currentVersion returns the installed version of the tool that its shim
+// runs. When no version is specified, the only installed version is used.
+func (t managedTool) currentVersion() (ToolVersion, error) {
+	desiredVersion, source, ok, err := t.desiredVersion()
+	if err != nil {
+		return ToolVersion{}, err
+	}
 	if !ok {
-		availableVersions, foundAnyVersions, err := t.listInstalledVersions()
+		availableVersions, foundAnyVersions, err := t.indexedVersions()
 		if err != nil {
-			return err
+			return ToolVersion{}, err
 		}
 		if !foundAnyVersions {
-			return fmt.Errorf("no versions of %s are installed, please see the `%s install` command to install it", t.name, callMeProgName)
+			return ToolVersion{}, fmt.Errorf("no versions of %s are installed, please see the `%s install` command to install it", t.name, callMeProgName)
 		}
 		if len(availableVersions) > 1 {
-			return fmt.Errorf(`please specify which version of %s you would like to run, by setting the %s environment variable to a valid version, or to "latest" to use the latest installed version.`, t.name, t.envVarName())
+			return ToolVersion{}, noVersionSelectedError{toolName: t.name, envVarName: t.envVarName()}
 		}
 		desiredVersion = availableVersions[0]
+		source = versionSource{onlyInstalled: true}
 		debugLog.Printf("selecting the only available version %s for tool %s", desiredVersion, t.name)
 	}
+	if isSystemVersion(desiredVersion) {
+		systemCommandPath, ok, err := t.systemCommandPath(desiredVersion)
+		if err != nil {
+			return ToolVersion{}, err
+		}
+		if !ok {
+			return ToolVersion{}, fmt.Errorf("%s is not found in PATH outside of %s, the system version is specified by %s", t.name, t.jkl.shimsDir, source)
+		}
+		return ToolVersion{
+			Tool:    t.name,
+			Version: desiredVersion,
+			Path:    systemCommandPath,
+			Source:  source.String(),
+		}, nil
+	}
 	installedCommandPath, ok, err := t.path(desiredVersion)
 	if err != nil {
-		return err
+		return ToolVersion{}, err
 	}
 	if !ok {
-		return fmt.Errorf("version %s of %s is not installed please see the `%s install` command to install it", desiredVersion, t.name, callMeProgName)
+		// A binary following the naming convention of user-installed versions
+		// satisfies the version without installing it.
+		installedCommandPath, ok, err = t.systemCommandPath(desiredVersion)
+		if err != nil {
+			return ToolVersion{}, err
+		}
+	}
+	if !ok {
+		return ToolVersion{}, versionNotInstalledError{toolName: t.name, version: desiredVersion, source: source}
+	}
+	return ToolVersion{
+		Tool:    t.name,
+		Version: desiredVersion,
+		Path:    installedCommandPath,
+		Source:  source.String(),
+	}, nil
+}
+
+// isSystemVersion returns true if the version is the "system" keyword, which
+// selects a binary of the tool that JKL did not install.
+func isSystemVersion(version string) bool {
+	return strings.EqualFold(version, "system")
+}
+
+// systemCommandPath returns the path to a binary of the specified version of
+// the tool that JKL did not install, found in the PATH outside of the shims
+// directory. The "system" version is the binary named after the tool, and
+// other versions are binaries named like tool.x.y.z or tool-x.y.z.
+func (t managedTool) systemCommandPath(version string) (commandPath string, found bool, err error) {
+	if isSystemVersion(version) {
+		commandPath, err = FindSystemCommand(t.name, t.jkl.shimsDir)
+	} else {
+		commandPath, err = FindCommandVersion(t.name, version, t.jkl.shimsDir)
 	}
-	err = ExecCommand(append([]string{installedCommandPath}, args...))
 	if err != nil {
-		return err
+		return "", false, err
 	}
-	return nil
+	return commandPath, commandPath != "", nil
 }
 
 // path returns the full path to the specified version of the
@@ -110,29 +212,86 @@ func (t managedTool) uninstallVersion(version string) error {
 	return nil
 }
 
+// versionSource describes where the desired version of a tool was
+// specified.
+type versionSource struct {
+	envVarName    string // an environment variable
+	filePath      string // an ASDF configuration file
+	line          int    // the line of filePath
+	global        bool   // filePath is the global versions file
+	onlyInstalled bool   // no version was specified and one version is installed
+	latest        bool   // the version was specified as "latest"
+}
+
+func (s versionSource) String() string {
+	var desc string
+	switch {
+	case s.envVarName != "":
+		desc = fmt.Sprintf("the %s environment variable", s.envVarName)
+	case s.global:
+		desc = fmt.Sprintf("the global version in %s line %d", s.filePath, s.line)
+	case s.filePath != "":
+		desc = fmt.Sprintf("%s line %d", s.filePath, s.line)
+	case s.onlyInstalled:
+		desc = "the only installed version"
+	}
+	if s.latest {
+		desc += ", as the latest installed version"
+	}
+	return desc
+}
+
 // desiredVersion returns the version of the specified tool desired by
-// configuration files or an environment variable. IF the version is `latest`, the latest installed version will be returned.
-func (t managedTool) desiredVersion() (desiredVersion string, found bool, err error) {
+// an environment variable, ASDF configuration files, or the global version set
+// using `jkl global`, in that order, and where that version was specified.
+// IF the version is `latest`, the latest installed version will be returned.
+func (t managedTool) desiredVersion() (desiredVersion string, source versionSource, found bool, err error) {
 	envVarName := t.envVarName()
 	desiredVersion = os.Getenv(envVarName)
+	source.envVarName = envVarName
 	if desiredVersion == "" {
 		debugLog.Printf("environment variable %q is not set, looking in config files for the desired %s version", envVarName, t.name)
 		var ok bool
-		// ToDo: Our own config file is not yet implemented.
-		desiredVersion, ok, err = FindASDFToolVersion(t.name)
+		desiredVersion, source, ok, err = t.configuredVersion()
 		if err != nil {
-			return "", false, err
+			return "", versionSource{}, false, err
 		}
 		if !ok {
 			debugLog.Printf("No desired version specified for %q", t.name)
-			return "", false, nil
+			return "", versionSource{}, false, nil
 		}
 	}
-	debugLog.Printf("desired version %q specified for %s\n", desiredVersion, t.name)
+	debugLog.Printf("desired version %q specified for %s by %s\n", desiredVersion, t.name, source)
 	if strings.ToLower(desiredVersion) == "latest" {
-		return t.latestInstalledVersion()
+		source.latest = true
+		latestVersion, ok, err := t.latestInstalledVersion()
+		return latestVersion, source, ok, err
 	}
-	return desiredVersion, true, nil
+	return desiredVersion, source, true, nil
+}
+
+// configuredVersion returns the version of the specified tool in ASDF
+// configuration files, or the global version set using `jkl global`, in that
+// order, and where that version was specified. Unlike desiredVersion, the
+// environment variable is not consulted and a version of `latest` is returned
+// unchanged.
+func (t managedTool) configuredVersion() (version string, source versionSource, found bool, err error) {
+	version, source.filePath, source.line, found, err = findASDFToolVersionLine(t.name, withASDFConfigLookupCacheDir(filepath.Join(t.jkl.installsDir, asdfConfigLookupSubDir)))
+	if err != nil {
+		return "", versionSource{}, false, err
+	}
+	if !found {
+		version, source.line, found, err = t.globalVersion()
+		if err != nil {
+			return "", versionSource{}, false, err
+		}
+		source.filePath = t.jkl.globalVersionsFile
+		source.global = true
+	}
+	if !found {
+		return "", versionSource{}, false, nil
+	}
+	return version, source, true, nil
 }
 
 // envVarName returns the name of the environment
@@ -159,6 +318,10 @@ func (t managedTool) listInstalledVersions() (versions []string, found bool, err
 			return err
 		}
 		if path != "." && d.IsDir() {
+			if strings.HasPrefix(d.Name(), ".") {
+				// Hidden directories are in-progress installations.
+				return fs.SkipDir
+			}
 			versions = append(versions, path)
 			found = true
 			return nil
@@ -175,7 +338,7 @@ func (t managedTool) listInstalledVersions() (versions []string, found bool, err
 	return sortedVersions, true, nil
 }
 
-func (t managedTool) uninstallAllVersions() error {
+func (t managedTool) uninstallAllVersions(ctx context.Context) error {
 	uninstallErrs := new(multierror.Error)
 	allVersions, foundAnyVersions, err := t.listInstalledVersions()
 	if err != nil {
@@ -203,6 +366,12 @@ func (t managedTool) uninstallAllVersions() error {
 		// the condition discoverable if debug logging is enabled.
 		debugLog.Printf("cannot remove directory %q after having removed %s: %v\n", topLevelToolDir, t.name, err)
 	}
+	t.removeSource()
+	shimsLock, err := t.jkl.lock(ctx, "shims")
+	if err != nil {
+		return err
+	}
+	defer shimsLock.unlock()
 	shim := filepath.Join(t.jkl.shimsDir, t.name)
 	debugLog.Printf("removing shim %s\n", shim)
 	err = os.Remove(shim)
@@ -212,10 +381,107 @@ func (t managedTool) uninstallAllVersions() error {
 	return nil
 }
 
+// versionIndexPath returns the path of the version index of the tool, which
+// lists its installed versions, oldest first, one per line. The index lets
+// shims select a version without listing and sorting the tool directory.
+func (t managedTool) versionIndexPath() string {
+	return filepath.Join(t.jkl.installsDir, "."+t.name+".versions")
+}
+
+// updateVersionIndex rewrites the version index of the tool from its installed
+// versions, removing the index if no versions are installed. The caller
+// should hold the lock for the tool.
+// Errors are only logged, as shims list installed versions when the index is
+// missing or out of date.
+func (t managedTool) updateVersionIndex() {
+	versions, _, err := t.listInstalledVersions()
+	if err != nil {
+		debugLog.Printf("cannot list installed versions of %s to update its version index: %v", t.name, err)
+		os.Remove(t.versionIndexPath())
+		return
+	}
+	t.saveVersionIndex(versions)
+}
+
+// saveVersionIndex writes the specified installed versions to the version
+// index of the tool. The caller should hold the lock for the tool.
+func (t managedTool) saveVersionIndex(versions []string) {
+	indexPath := t.versionIndexPath()
+	err := t.writeVersionIndex(indexPath, versions)
+	if err != nil {
+		debugLog.Printf("cannot update the version index %s: %v", indexPath, err)
+		os.Remove(indexPath)
+		return
+	}
+	debugLog.Printf("updated the version index %s", indexPath)
+}
+
+func (t managedTool) writeVersionIndex(indexPath string, versions []string) error {
+	if len(versions) == 0 {
+		err := os.Remove(indexPath)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	// The index is written beside the tool directory, not inside it, so
+	// writing the index does not change the modification time of the tool
+	// directory. See indexedVersions().
+	f, err := os.CreateTemp(filepath.Dir(indexPath), filepath.Base(indexPath)+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // fails harmlessly once renamed
+	_, err = f.WriteString(strings.Join(versions, "\n") + "\n")
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), indexPath)
+}
+
+// indexedVersions returns the installed versions of the tool, oldest first,
+// from its version index. Installed versions are listed instead if the index
+// is missing, or is older than the tool directory because versions were added
+// or removed without updating the index, such as by a version of jkl which
+// did not maintain the index. The listed versions are then saved to the index
+// for the next run, unless another process holds the lock for the tool.
+func (t managedTool) indexedVersions() (versions []string, found bool, err error) {
+	indexPath := t.versionIndexPath()
+	indexStat, err := os.Stat(indexPath)
+	if err == nil {
+		toolDirStat, err := os.Stat(filepath.Join(t.jkl.installsDir, t.name))
+		if err == nil && !toolDirStat.ModTime().After(indexStat.ModTime()) {
+			b, err := os.ReadFile(indexPath)
+			if err == nil {
+				versions = strings.Fields(string(b))
+				return versions, len(versions) > 0, nil
+			}
+		}
+	}
+	debugLog.Printf("the version index %s is missing or out of date, listing installed versions of %s", indexPath, t.name)
+	toolLock, locked, err := t.jkl.tryLock(toolLockName(t.name))
+	if err != nil {
+		debugLog.Printf("not updating the version index %s: %v", indexPath, err)
+	}
+	versions, found, err = t.listInstalledVersions()
+	if locked {
+		defer toolLock.unlock()
+		if err == nil {
+			t.saveVersionIndex(versions)
+		}
+	}
+	return versions, found, err
+}
+
 // latestInstalledVersion returns the latest version number that is installed
 // of the specified tool.
 func (t managedTool) latestInstalledVersion() (latestVersion string, found bool, err error) {
-	versions, ok, err := t.listInstalledVersions()
+	versions, ok, err := t.indexedVersions()
 	if err != nil {
 		return "", false, err
 	}
@@ -0,0 +1,721 @@
+package jkl_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ivanfetch/jkl"
+)
+
+func TestToolCommandPathUsesVersionIndex(t *testing.T) {
+	// Not parallel, as the desired version is set in the environment.
+	t.Setenv("JKL_TOOL", "latest")
+	tempDir := t.TempDir()
+	server := newFakeGithubServer(t, "jkltest/tool", "v1.0.0", "v1.1.0")
+	j := newTestJKL(t, tempDir, server)
+	for _, spec := range []string{"github:jkltest/tool:1.1.0", "github:jkltest/tool:1.0.0"} {
+		_, err := j.Install(context.Background(), spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	indexPath := filepath.Join(tempDir, "installs/.tool.versions")
+	gotIndex, err := os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "v1.0.0\nv1.1.0\n"; string(gotIndex) != want {
+		t.Fatalf("want the version index to contain %q, got %q", want, gotIndex)
+	}
+	got, err := j.ToolCommandPath("tool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(tempDir, "installs/tool/v1.1.0/tool"); got != want {
+		t.Fatalf("want the latest version %q, got %q", want, got)
+	}
+	// Removing a version without jkl leaves the index out of date.
+	err = os.RemoveAll(filepath.Join(tempDir, "installs/tool/v1.1.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Filesystem timestamps may be too coarse to show the tool directory
+	// changed after the index was written.
+	later := time.Now().Add(time.Minute)
+	err = os.Chtimes(filepath.Join(tempDir, "installs/tool"), later, later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = j.ToolCommandPath("tool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(tempDir, "installs/tool/v1.0.0/tool"); got != want {
+		t.Fatalf("want the latest version %q when the index is out of date, got %q", want, got)
+	}
+	// The out of date index is rewritten for the next run.
+	gotIndex, err = os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "v1.0.0\n"; string(gotIndex) != want {
+		t.Fatalf("want the out of date version index rewritten to contain %q, got %q", want, gotIndex)
+	}
+	// Versions installed before jkl maintained the index are indexed by reshim.
+	err = os.Remove(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = j.Reshim(context.Background(), io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotIndex, err = os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "v1.0.0\n"; string(gotIndex) != want {
+		t.Fatalf("want reshim to rebuild the version index containing %q, got %q", want, gotIndex)
+	}
+	err = j.Uninstall(context.Background(), "tool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = os.Stat(indexPath)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("want the version index removed after uninstalling all versions, got: %v", err)
+	}
+}
+
+func TestGlobalVersion(t *testing.T) {
+	// Not parallel, as the desired version is set in the environment.
+	t.Setenv("JKL_TOOL", "")
+	tempDir := t.TempDir()
+	server := newFakeGithubServer(t, "jkltest/tool", "v1.0.0", "v1.1.0")
+	j := newTestJKL(t, tempDir, server)
+	for _, spec := range []string{"github:jkltest/tool:1.0.0", "github:jkltest/tool:1.1.0"} {
+		_, err := j.Install(context.Background(), spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := j.ToolCommandPath("tool")
+	if err == nil || !strings.Contains(err.Error(), "jkl global tool <version>") {
+		t.Fatalf("want an error suggesting a global version when multiple versions are installed, got: %v", err)
+	}
+	globalVersionsFile := filepath.Join(tempDir, "tool-versions")
+	err = os.WriteFile(globalVersionsFile, []byte("# Default versions\nother 2.0.0\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = j.SetGlobalVersion(context.Background(), "tool", "2.0")
+	if err == nil {
+		t.Fatal("want an error setting a global version that is not installed")
+	}
+	gotVersion, err := j.SetGlobalVersion(context.Background(), "tool", "1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotVersion != "v1.0.0" {
+		t.Fatalf("want the partial global version to match v1.0.0, got %q", gotVersion)
+	}
+	got, err := j.ToolCommandPath("tool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(tempDir, "installs/tool/v1.0.0/tool"); got != want {
+		t.Fatalf("want the global version %q, got %q", want, got)
+	}
+	gotFile, err := os.ReadFile(globalVersionsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "# Default versions\nother 2.0.0\ntool v1.0.0\n"; string(gotFile) != want {
+		t.Fatalf("want the global versions file to contain %q, got %q", want, gotFile)
+	}
+	t.Setenv("JKL_TOOL", "v1.1.0")
+	got, err = j.ToolCommandPath("tool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(tempDir, "installs/tool/v1.1.0/tool"); got != want {
+		t.Fatalf("want the environment variable to override the global version with %q, got %q", want, got)
+	}
+	err = j.UnsetGlobalVersion(context.Background(), "tool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, found, err := j.GlobalVersion("tool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found {
+		t.Fatal("want no global version after unsetting it")
+	}
+}
+
+func TestSetLocalVersion(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+	server := newFakeGithubServer(t, "jkltest/tool", "v1.0.0", "v1.1.0")
+	j := newTestJKL(t, tempDir, server)
+	for _, spec := range []string{"github:jkltest/tool:1.0.0", "github:jkltest/tool:1.1.0"} {
+		_, err := j.Install(context.Background(), spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	projectDir := filepath.Join(tempDir, "project")
+	err := os.Mkdir(projectDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = j.SetLocalVersion(projectDir, "tool", "1.2")
+	if err == nil {
+		t.Fatal("want an error setting a local version that is not installed")
+	}
+	gotVersion, err := j.SetLocalVersion(projectDir, "tool", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotVersion != "v1.1.0" {
+		t.Fatalf("want the partial local version to match v1.1.0, got %q", gotVersion)
+	}
+	gotVersion, found, err := jkl.FindASDFToolVersion("tool", jkl.WithASDFConfigSearchStartDir(projectDir), jkl.WithASDFConfigSearchRootDir(projectDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found || gotVersion != "v1.1.0" {
+		t.Fatalf("want version v1.1.0 in the project %s file, got %q (found=%v)", jkl.ASDFConfigFileName, gotVersion, found)
+	}
+}
+
+func TestCurrentVersion(t *testing.T) {
+	// Not parallel, as the environment and current directory are changed.
+	t.Setenv("JKL_TOOL", "")
+	tempDir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newFakeGithubServer(t, "jkltest/tool", "v1.0.0", "v1.1.0")
+	j := newTestJKL(t, tempDir, server)
+	_, err = j.Install(context.Background(), "github:jkltest/tool:1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	projectDir := filepath.Join(tempDir, "project")
+	err = os.Mkdir(projectDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	originalDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(originalDir)
+	err = os.Chdir(projectDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each step configures a higher-precedence version.
+	steps := []struct {
+		description string
+		configure   func() error
+		wantVersion string
+		wantSource  string
+	}{
+		{
+			description: "the only installed version",
+			configure:   func() error { return nil },
+			wantVersion: "v1.0.0",
+			wantSource:  "the only installed version",
+		},
+		{
+			description: "global version",
+			configure: func() error {
+				_, err := j.Install(context.Background(), "github:jkltest/tool:1.1.0")
+				if err != nil {
+					return err
+				}
+				_, err = j.SetGlobalVersion(context.Background(), "tool", "latest")
+				return err
+			},
+			wantVersion: "v1.1.0",
+			wantSource:  "the global version in " + filepath.Join(tempDir, "tool-versions") + " line 1, as the latest installed version",
+		},
+		{
+			description: "project tool-versions file",
+			configure: func() error {
+				return os.WriteFile(filepath.Join(projectDir, jkl.ASDFConfigFileName), []byte("# Project tools\ntool v1.0.0\n"), 0600)
+			},
+			wantVersion: "v1.0.0",
+			wantSource:  filepath.Join(projectDir, jkl.ASDFConfigFileName) + " line 2",
+		},
+		{
+			description: "environment variable",
+			configure: func() error {
+				return os.Setenv("JKL_TOOL", "v1.1.0")
+			},
+			wantVersion: "v1.1.0",
+			wantSource:  "the JKL_TOOL environment variable",
+		},
+	}
+	for _, step := range steps {
+		err := step.configure()
+		if err != nil {
+			t.Fatalf("configuring %s: %v", step.description, err)
+		}
+		got, err := j.CurrentVersion("tool")
+		if err != nil {
+			t.Fatalf("%s: %v", step.description, err)
+		}
+		if got.Version != step.wantVersion || got.Source != step.wantSource {
+			t.Fatalf("%s: want version %q set by %q, got %q set by %q", step.description, step.wantVersion, step.wantSource, got.Version, got.Source)
+		}
+		if want := filepath.Join(tempDir, "installs/tool", step.wantVersion, "tool"); got.Path != want {
+			t.Fatalf("%s: want path %q, got %q", step.description, want, got.Path)
+		}
+	}
+}
+
+func TestCurrentVersionCachesToolVersionsLookups(t *testing.T) {
+	// Not parallel, as the environment and current directory are changed.
+	t.Setenv("JKL_TOOL", "")
+	tempDir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newFakeGithubServer(t, "jkltest/tool", "v1.0.0", "v1.1.0")
+	j := newTestJKL(t, tempDir, server)
+	for _, spec := range []string{"github:jkltest/tool:1.0.0", "github:jkltest/tool:1.1.0"} {
+		_, err := j.Install(context.Background(), spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The cached lookup includes paths which need quoting.
+	projectFile := filepath.Join(tempDir, "my project", jkl.ASDFConfigFileName)
+	subDir := filepath.Join(tempDir, "my project/sub")
+	subDirFile := filepath.Join(subDir, jkl.ASDFConfigFileName)
+	err = os.MkdirAll(subDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	originalDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(originalDir)
+	err = os.Chdir(subDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Lookups which read recently modified files are not cached, so files are
+	// written with modification times in the past, which differ per step.
+	modTime := time.Now().Add(-time.Hour)
+	writeFile := func(filePath, content string) error {
+		err := os.WriteFile(filePath, []byte(content), 0600)
+		if err != nil {
+			return err
+		}
+		modTime = modTime.Add(time.Second)
+		return os.Chtimes(filePath, modTime, modTime)
+	}
+	steps := []struct {
+		description string
+		configure   func() error
+		wantVersion string
+		wantSource  string
+	}{
+		{
+			description: "project tool-versions file",
+			configure:   func() error { return writeFile(projectFile, "tool v1.0.0\n") },
+			wantVersion: "v1.0.0",
+			wantSource:  projectFile + " line 1",
+		},
+		{
+			description: "cached lookup",
+			configure: func() error {
+				// A change which keeps the size and modification time of the
+				// file is not noticed, showing the cached lookup is used.
+				err := os.WriteFile(projectFile, []byte("tool v1.1.0\n"), 0600)
+				if err != nil {
+					return err
+				}
+				return os.Chtimes(projectFile, modTime, modTime)
+			},
+			wantVersion: "v1.0.0",
+			wantSource:  projectFile + " line 1",
+		},
+		{
+			description: "modified tool-versions file",
+			configure:   func() error { return writeFile(projectFile, "# Project tools\ntool v1.1.0\n") },
+			wantVersion: "v1.1.0",
+			wantSource:  projectFile + " line 2",
+		},
+		{
+			description: "added tool-versions file in the current directory",
+			configure:   func() error { return writeFile(subDirFile, "tool v1.0.0\n") },
+			wantVersion: "v1.0.0",
+			wantSource:  subDirFile + " line 1",
+		},
+		{
+			description: "removed tool-versions file in the current directory",
+			configure:   func() error { return os.Remove(subDirFile) },
+			wantVersion: "v1.1.0",
+			wantSource:  projectFile + " line 2",
+		},
+		{
+			description: "recently modified tool-versions file",
+			configure: func() error {
+				return os.WriteFile(projectFile, []byte("tool v1.0.0\n"), 0600)
+			},
+			wantVersion: "v1.0.0",
+			wantSource:  projectFile + " line 1",
+		},
+		{
+			description: "recently modified tool-versions file changed again",
+			configure: func() error {
+				// The lookup which read the recently modified file was not
+				// cached, so a change within the timestamp granularity of the
+				// filesystem is noticed.
+				stat, err := os.Stat(projectFile)
+				if err != nil {
+					return err
+				}
+				err = os.WriteFile(projectFile, []byte("tool v1.1.0\n"), 0600)
+				if err != nil {
+					return err
+				}
+				return os.Chtimes(projectFile, stat.ModTime(), stat.ModTime())
+			},
+			wantVersion: "v1.1.0",
+			wantSource:  projectFile + " line 1",
+		},
+		{
+			description: "current directory via a symlink",
+			configure: func() error {
+				linkDir := filepath.Join(tempDir, "link")
+				err := os.Symlink(subDir, linkDir)
+				if err != nil {
+					return err
+				}
+				// The current directory is taken from PWD if it matches.
+				t.Setenv("PWD", linkDir)
+				return writeFile(projectFile, "tool v1.0.0\n")
+			},
+			wantVersion: "v1.0.0",
+			wantSource:  projectFile + " line 1",
+		},
+	}
+	for _, step := range steps {
+		err := step.configure()
+		if err != nil {
+			t.Fatalf("configuring %s: %v", step.description, err)
+		}
+		got, err := j.CurrentVersion("tool")
+		if err != nil {
+			t.Fatalf("%s: %v", step.description, err)
+		}
+		if got.Version != step.wantVersion || got.Source != step.wantSource {
+			t.Fatalf("%s: want version %q set by %q, got %q set by %q", step.description, step.wantVersion, step.wantSource, got.Version, got.Source)
+		}
+	}
+	// Lookups from a start directory including symlinks are not cached.
+	entries, err := os.ReadDir(filepath.Join(tempDir, "installs/.lookups"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("want one cached lookup for the current directory, got %d", len(entries))
+	}
+}
+
+// BenchmarkToolCommandPath measures how long a shim takes to determine which
+// version of a tool to run, excluding starting the process. Nothing is
+// reused between iterations, as each run of a shim is a new process.
+func BenchmarkToolCommandPath(b *testing.B) {
+	tempDir := b.TempDir()
+	server := newFakeGithubServer(b, "jkltest/tool", "v1.0.0", "v1.1.0", "v1.2.0")
+	j := newTestJKL(b, tempDir, server)
+	for _, version := range []string{"1.0.0", "1.1.0", "1.2.0"} {
+		_, err := j.Install(context.Background(), "github:jkltest/tool:"+version)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	_, err := j.SetGlobalVersion(context.Background(), "tool", "1.0.0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	// A project directory nested below a .tool-versions file.
+	projectDir := filepath.Join(tempDir, "project", strings.Repeat("sub/", 10))
+	projectFile := filepath.Join(tempDir, "project", jkl.ASDFConfigFileName)
+	// Directories with no .tool-versions file, where the global version is
+	// used.
+	otherDir := filepath.Join(tempDir, "other")
+	deepDir := filepath.Join(tempDir, "deep", strings.Repeat("sub/", 50))
+	for _, dir := range []string{projectDir, otherDir, deepDir} {
+		err = os.MkdirAll(dir, 0700)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	err = os.WriteFile(projectFile, []byte("other 1.0.0\ntool v1.1.0\n"), 0600)
+	if err != nil {
+		b.Fatal(err)
+	}
+	// Cached lookups check recently modified directories and files more
+	// thoroughly, as they would be checked after the project is set up.
+	modTime := time.Now().Add(-time.Hour)
+	for _, p := range []string{projectFile, projectDir, otherDir, deepDir} {
+		for ; p != tempDir; p = filepath.Dir(p) {
+			err = os.Chtimes(p, modTime, modTime)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	originalDir, err := os.Getwd()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.Chdir(originalDir)
+	benchmarks := []struct {
+		description   string
+		dir           string // the current directory
+		envVersion    string // the JKL_TOOL environment variable
+		noIndex       bool   // remove the version index before running
+		noLookupCache bool   // remove cached lookups of .tool-versions files before running
+	}{
+		{
+			description: "version from the environment",
+			dir:         projectDir,
+			envVersion:  "v1.1.0",
+		},
+		{
+			description: "latest version from the index",
+			dir:         projectDir,
+			envVersion:  "latest",
+		},
+		{
+			description: "version from a tool-versions file",
+			dir:         projectDir,
+		},
+		{
+			description:   "version from a tool-versions file without a cached lookup",
+			dir:           projectDir,
+			noLookupCache: true,
+		},
+		{
+			description: "latest version without an index",
+			dir:         projectDir,
+			envVersion:  "latest",
+			noIndex:     true,
+		},
+		{
+			description: "global version",
+			dir:         otherDir,
+		},
+		{
+			description: "global version below many directories",
+			dir:         deepDir,
+		},
+		{
+			description:   "global version below many directories without a cached lookup",
+			dir:           deepDir,
+			noLookupCache: true,
+		},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.description, func(b *testing.B) {
+			b.Setenv("JKL_TOOL", bm.envVersion)
+			err := os.Chdir(bm.dir)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// The index and cached lookups are rebuilt by the previous
+				// iteration.
+				if bm.noIndex || bm.noLookupCache {
+					b.StopTimer()
+					if bm.noIndex {
+						err = os.Remove(filepath.Join(tempDir, "installs/.tool.versions"))
+					} else {
+						err = os.RemoveAll(filepath.Join(tempDir, "installs/.lookups"))
+					}
+					if err != nil && !errors.Is(err, fs.ErrNotExist) {
+						b.Fatal(err)
+					}
+					b.StartTimer()
+				}
+				_, err := j.ToolCommandPath("tool")
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkShim measures running a tool via its shim, which is the jkl binary
+// built from cmd/jkl, and running the tool directly. The difference is the
+// overhead of the shim, including starting jkl.
+func BenchmarkShim(b *testing.B) {
+	homeDir := b.TempDir()
+	jklDir := filepath.Join(homeDir, ".jkl")
+	server := newFakeGithubServer(b, "jkltest/tool", "v1.0.0", "v1.1.0")
+	j := newTestJKL(b, jklDir, server)
+	for _, spec := range []string{"github:jkltest/tool:1.0.0", "github:jkltest/tool:1.1.0"} {
+		_, err := j.Install(context.Background(), spec)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	jklBinary := filepath.Join(homeDir, "jkl")
+	output, err := exec.Command("go", "build", "-o", jklBinary, "./cmd/jkl").CombinedOutput()
+	if err != nil {
+		b.Fatalf("building jkl: %v\n%s", err, output)
+	}
+	// The shim created while installing points to the test binary.
+	shim := filepath.Join(jklDir, "bin/tool")
+	err = os.Remove(shim)
+	if err != nil {
+		b.Fatal(err)
+	}
+	err = os.Symlink(jklBinary, shim)
+	if err != nil {
+		b.Fatal(err)
+	}
+	projectDir := filepath.Join(homeDir, "project")
+	projectFile := filepath.Join(projectDir, jkl.ASDFConfigFileName)
+	err = jkl.WriteASDFToolVersion(projectFile, "tool", "v1.0.0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	// Cached lookups check recently modified directories and files more
+	// thoroughly.
+	modTime := time.Now().Add(-time.Hour)
+	for _, p := range []string{projectFile, projectDir} {
+		err = os.Chtimes(p, modTime, modTime)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	benchmarks := []struct {
+		description string
+		command     string
+		envVersion  string // the JKL_TOOL environment variable
+	}{
+		{
+			description: "tool run directly",
+			command:     filepath.Join(jklDir, "installs/tool/v1.1.0/tool"),
+		},
+		{
+			description: "version from the environment",
+			command:     shim,
+			envVersion:  "v1.1.0",
+		},
+		{
+			description: "version from a tool-versions file",
+			command:     shim,
+		},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.description, func(b *testing.B) {
+			env := []string{"HOME=" + homeDir, "PATH=" + os.Getenv("PATH")}
+			if bm.envVersion != "" {
+				env = append(env, "JKL_TOOL="+bm.envVersion)
+			}
+			for i := 0; i < b.N; i++ {
+				cmd := exec.Command(bm.command)
+				cmd.Dir = projectDir
+				cmd.Env = env
+				output, err := cmd.CombinedOutput()
+				if err != nil {
+					b.Fatalf("running %s: %v\n%s", bm.command, err, output)
+				}
+			}
+		})
+	}
+}
+
+func TestSystemVersion(t *testing.T) {
+	// Not parallel, as the environment is changed.
+	tempDir := t.TempDir()
+	server := newFakeGithubServer(t, "jkltest/tool", "v1.0.0")
+	j := newTestJKL(t, tempDir, server)
+	_, err := j.Install(context.Background(), "github:jkltest/tool:1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	systemDir := filepath.Join(tempDir, "system")
+	err = os.Mkdir(systemDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fileName := range []string{"tool", "tool-2.0.0"} {
+		err = os.WriteFile(filepath.Join(systemDir, fileName), []byte("#!/bin/sh\n"), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The shim must be skipped, to find the tool in the system directory.
+	t.Setenv("PATH", filepath.Join(tempDir, "bin")+string(os.PathListSeparator)+systemDir)
+	testCases := []struct {
+		description string
+		version     string
+		wantPath    string
+		expectError bool
+	}{
+		{
+			description: "system version",
+			version:     "system",
+			wantPath:    filepath.Join(systemDir, "tool"),
+		},
+		{
+			description: "version installed outside of jkl",
+			version:     "2.0.0",
+			wantPath:    filepath.Join(systemDir, "tool-2.0.0"),
+		},
+		{
+			description: "version installed by jkl",
+			version:     "1.0.0",
+			wantPath:    filepath.Join(tempDir, "installs/tool/v1.0.0/tool"),
+		},
+		{
+			description: "version which is not installed",
+			version:     "3.0.0",
+			expectError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Setenv("JKL_TOOL", tc.version)
+		got, err := j.ToolCommandPath("tool")
+		if err != nil && !tc.expectError {
+			t.Fatalf("%s: %v", tc.description, err)
+		}
+		if err == nil && tc.expectError {
+			t.Fatalf("%s: an error is expected, got path %q", tc.description, got)
+		}
+		if got != tc.wantPath {
+			t.Fatalf("%s: want path %q, got %q", tc.description, tc.wantPath, got)
+		}
+	}
+	got, err := j.ToolVersionCommandPath(context.Background(), "tool:system", io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(systemDir, "tool"); got != want {
+		t.Fatalf("want exec path %q, got %q", want, got)
+	}
+}
@@ -32,3 +32,13 @@ func (j JKL) NewToolSpec(toolSpec string) (ToolSpec, error) {
 	t.source = toolSpecFields[1]
 	return t, nil
 }
+
+// isToolProvider returns true if the name is one of the providers accepted in
+// a tool specification.
+func isToolProvider(name string) bool {
+	switch strings.ToLower(name) {
+	case "github", "gh", "hashicorp", "hashi":
+		return true
+	}
+	return false
+}
@@ -1,6 +1,8 @@
 package jkl
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -11,9 +13,14 @@ import (
 // UpdateSelf downloads the latest jkl binary and overwrites the currently
 // executing one. The new binary is run, to verify it reports the expected
 // newer version.
-func (j JKL) UpdateSelf() (newVersion string, isNewerVersion bool, err error) {
+func (j JKL) UpdateSelf(ctx context.Context) (newVersion string, isNewerVersion bool, err error) {
 	debugLog.Printf("updating %s from %s to the latest version", j.executable, Version)
-	downloadedJKLPath, newVersion, isNewerVersion, err := j.DownloadAndExtractlaterJKLVersion()
+	updateLock, err := j.lock(ctx, "update-self")
+	if err != nil {
+		return "", false, err
+	}
+	defer updateLock.unlock()
+	downloadedJKLPath, newVersion, isNewerVersion, err := j.DownloadAndExtractlaterJKLVersion(ctx)
 	if err != nil {
 		return
 	}
@@ -21,7 +28,8 @@ func (j JKL) UpdateSelf() (newVersion string, isNewerVersion bool, err error) {
 		return
 	}
 	debugLog.Printf("downloaded jkl %s to %q\n", newVersion, downloadedJKLPath)
-	versionReportedByNewBinary, err := getVersionOfJKLBinary(downloadedJKLPath)
+	defer os.RemoveAll(filepath.Dir(downloadedJKLPath))
+	versionReportedByNewBinary, err := getVersionOfJKLBinary(ctx, downloadedJKLPath)
 	if err != nil {
 		return newVersion, isNewerVersion, fmt.Errorf("while executing a newly downloaded JKL binary (%s version -v) to verify its version is %q: %v: %s", downloadedJKLPath, newVersion, err, versionReportedByNewBinary)
 	}
@@ -43,12 +51,15 @@ func (j JKL) UpdateSelf() (newVersion string, isNewerVersion bool, err error) {
 // The JKL binary will be set to the file-mode of the current binary.
 // It returns the path to the downloaded binary, the latestversion number, and whether a
 // newer version exists.
-func (j JKL) DownloadAndExtractlaterJKLVersion() (binaryPath, matchedVersion string, newerVerAvailable bool, err error) {
-	g, err := NewGithubRepo("ivanfetch/jkl")
+func (j JKL) DownloadAndExtractlaterJKLVersion(ctx context.Context) (binaryPath, matchedVersion string, newerVerAvailable bool, err error) {
+	if j.offline {
+		return "", "", false, errors.New("jkl cannot be updated in offline mode")
+	}
+	g, err := NewGithubRepo("ivanfetch/jkl", WithHTTPClient(j.httpClient))
 	if err != nil {
 		return
 	}
-	latestTag, err := g.GetTagForLatestRelease()
+	latestTag, err := g.GetTagForLatestRelease(ctx)
 	if err != nil {
 		return
 	}
@@ -57,11 +68,16 @@ func (j JKL) DownloadAndExtractlaterJKLVersion() (binaryPath, matchedVersion str
 		return
 	}
 	newerVerAvailable = true
-	downloadPath, _, err := g.DownloadReleaseForTag(latestTag)
+	downloadPath, _, err := g.DownloadReleaseForTag(ctx, latestTag)
 	if err != nil {
 		return
 	}
-	_, err = ExtractFile(downloadPath)
+	defer func() {
+		if err != nil { // do not leave a partial update behind
+			os.RemoveAll(filepath.Dir(downloadPath))
+		}
+	}()
+	_, err = ExtractFile(ctx, downloadPath)
 	if err != nil {
 		return
 	}
@@ -80,9 +96,9 @@ func (j JKL) DownloadAndExtractlaterJKLVersion() (binaryPath, matchedVersion str
 
 // getVersionOfJKLBinary runs the specified jkl binary to determine its
 // version.
-func getVersionOfJKLBinary(binaryPath string) (version string, err error) {
-	cmd := exec.Command(binaryPath, "version", "-v") // returns only the version
-	cmd.Env = append(os.Environ(), `JKL_DEBUG=`)     // debug output can obscure the version output
+func getVersionOfJKLBinary(ctx context.Context, binaryPath string) (version string, err error) {
+	cmd := exec.CommandContext(ctx, binaryPath, "version", "-v") // returns only the version
+	cmd.Env = append(os.Environ(), `JKL_DEBUG=`)                 // debug output can obscure the version output
 	outputBytes, err := cmd.CombinedOutput()
 	returnedVersion := strings.TrimSuffix(string(outputBytes), "\n")
 	if err != nil {
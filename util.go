@@ -1,12 +1,14 @@
 package jkl
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
 
@@ -88,6 +90,7 @@ func CopyFile(filePath, destDir string) error {
 // permissions to 0755 so the resulting file is executable. IF destFilePath
 // minus the file name does
 // not exist, the directory wil be created.
+// The copy is flushed to disk before returning.
 func CopyExecutableToCreatedDir(sourceFilePath, destFilePath string) error {
 	_, err := os.Stat(sourceFilePath)
 	if err != nil {
@@ -124,6 +127,30 @@ func CopyExecutableToCreatedDir(sourceFilePath, destFilePath string) error {
 	if err != nil {
 		return fmt.Errorf("Cannot write to %s: %v", destFilePath, err)
 	}
+	err = d.Sync()
+	if err != nil {
+		return fmt.Errorf("cannot sync %s to disk: %v", destFilePath, err)
+	}
+	return nil
+}
+
+// syncDir flushes the specified directory to disk, to persist files
+// that have been created or renamed within it.
+// Windows does not support syncing directories, and persists renames
+// without it, so nothing is done there.
+func syncDir(dirName string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dirName)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	err = d.Sync()
+	if err != nil {
+		return fmt.Errorf("cannot sync directory %s to disk: %v", dirName, err)
+	}
 	return nil
 }
 
@@ -189,6 +216,7 @@ func getAliasesForArchitecture(arch string) []string {
 		// The `universal` alias should always be last in the list, this supports macOS
 		// binaries for amd64 and arm64.
 		"amd64": {"x86_64", "64bit", "64-bit", "universal"},
+		"arm64": {"aarch64"},
 	}
 	return archAliases[strings.ToLower(arch)]
 }
@@ -226,3 +254,18 @@ func sortVersions(versions []string) []string {
 	debugLog.Printf("sorted versions are: %v", versions)
 	return versions
 }
+
+// contextReader is an io.Reader which returns the context error once the
+// context is done, to stop copying a large file.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (n int, err error) {
+	err = c.ctx.Err()
+	if err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}